@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+)
+
+// CreateAPIKey creates an API key for a backend service.
+// @Summary Create an API key.
+// @Description Create a named API key with scopes, an optional UID allow-list and an optional expiry. The plaintext key is only returned in this response.
+// @Tags API Keys
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param requestBody body models.CreateAPIKeyRequest true "Create API Key Request"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /admin/api_keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var requestBody models.CreateAPIKeyRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	apiKey, key, err := services.CreateAPIKey(requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data: gin.H{
+			"api_key": apiKey,
+			"key":     key,
+		},
+	}
+	response.SendResponse(c)
+}
+
+// ListAPIKeys lists every API key.
+// @Summary List API keys.
+// @Description List every API key including revoked and expired ones. Key hashes are never returned.
+// @Tags API Keys
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Router /admin/api_keys [get]
+func ListAPIKeys(c *gin.Context) {
+	apiKeys, err := services.ListAPIKeys()
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"api_keys": apiKeys})
+}
+
+// RevokeAPIKey revokes an API key.
+// @Summary Revoke an API key.
+// @Description Revoke the API key with the given name. Requests made with it are rejected from then on.
+// @Tags API Keys
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param name path string true "API key name"
+// @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Router /admin/api_keys/{name} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := services.RevokeAPIKey(name); err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Message": "API key revoked"})
+}
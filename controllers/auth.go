@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"ledger-service/models"
@@ -10,13 +11,15 @@ import (
 
 // GenerateAccessToken generates new access tokens.
 // @Summary Generate new access tokens.
-// @Description Generate new access tokens for the provided email.
+// @Description Generate new access tokens for the provided email, with the scopes of the role assigned to it in AUTH_ROLES. Requires the admin scope, since the email is not verified here.
 // @Tags Tokens
 // @Accept  json
 // @Produce  json
 // @Param authReq body models.AuthRequest true "Auth Request"
 // @Success 200 {object} models.Response
 // @Success 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
 // @Router /access [post]
 func GenerateAccessToken(c *gin.Context) {
 	var requestBody models.AuthRequest
@@ -47,13 +50,14 @@ func GenerateAccessToken(c *gin.Context) {
 
 // Refresh handles the request for token refresh.
 // @Summary Handle the request for token refresh.
-// @Description Handle the request for token refresh by validating the refresh token, generating new access and refresh tokens and returning the response.
+// @Description Handle the request for token refresh by validating the refresh token, rotating it within its token family and returning new access and refresh tokens for the email in the token claims. Reusing an already rotated refresh token revokes the whole family.
 // @Tags Tokens
 // @Accept  json
 // @Produce  json
 // @Param requestBody body models.RefreshRequest true "Refresh Request"
 // @Success 200 {object} models.Response
 // @Success 400 {object} models.Response
+// @Failure 401 {object} models.Response
 // @Router /refresh [post]
 func Refresh(c *gin.Context) {
 	var requestBody models.RefreshRequest
@@ -64,27 +68,21 @@ func Refresh(c *gin.Context) {
 		Success:    false,
 	}
 
-	// check token validity
-	token, err := services.VerifyToken(requestBody.Token, models.TokenTypeRefresh)
+	// rotate the refresh token and issue a new pair for the verified identity
+	accessToken, refreshToken, claims, err := services.RotateRefreshToken(requestBody.Token)
 	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			response.StatusCode = http.StatusUnauthorized
+		}
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// delete old token
-	err = services.DeleteTokenById(token.ID)
-	if err != nil {
-		response.Message = err.Error()
-		response.SendResponse(c)
-		return
-	}
-
-	accessToken, refreshToken, err := services.GenerateAccessTokens(requestBody.Email)
 	response.StatusCode = http.StatusOK
 	response.Success = true
 	response.Data = gin.H{
-		"Email": requestBody.Email,
+		"Email": claims.Email,
 		"token": gin.H{
 			"access":  accessToken.GetResponseJson(),
 			"refresh": refreshToken.GetResponseJson()},
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/middlewares"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+	"strconv"
+)
+
+// CreateBatch posts a batch of credits and debits.
+// @Summary Post a batch of credits and debits.
+// @Description Post up to BATCH_MAX_ITEMS credits and debits, each with its own idempotency key. Atomic batches post every item or none; best-effort batches post every item that passes and report the others. Debits require the funds:debit scope.
+// @Tags Batches
+// @Accept  json
+// @Produce  json
+// @Param requestBody body models.CreateBatchRequest true "Create Batch Request"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /batches [post]
+func CreateBatch(c *gin.Context) {
+	var requestBody models.CreateBatchRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	if principal := middlewares.GetPrincipal(c); principal != nil {
+		for _, item := range requestBody.Items {
+			if item.Type == models.TransactionTypeDebit && !principal.HasScope(models.ScopeFundsDebit) {
+				models.SendErrorResponse(c, http.StatusForbidden, "missing scope "+models.ScopeFundsDebit)
+				return
+			}
+			if !principal.CanAccessUID(item.UID) {
+				models.SendErrorResponse(c, http.StatusForbidden, "api key is not allowed to access uid "+item.UID)
+				return
+			}
+		}
+	}
+
+	caller := "unknown"
+	if principal := middlewares.GetPrincipal(c); principal != nil {
+		caller = principal.Ref()
+	}
+	batch, err := services.CreateBatch(c, requestBody, caller, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"batch": batch},
+	}
+	response.SendResponse(c)
+}
+
+// GetBatch retrieves a batch.
+// @Summary Get a batch.
+// @Description Get the status of a batch and the result of each of its items.
+// @Tags Batches
+// @Produce  json
+// @Param id path int true "Batch ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Router /batches/{id} [get]
+func GetBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "Invalid batch ID")
+		return
+	}
+
+	batch, err := services.GetBatch(c, uint(id))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	// Callers restricted to some UIDs only see batches of those UIDs
+	if principal := middlewares.GetPrincipal(c); principal != nil {
+		for _, item := range batch.Items {
+			if !principal.CanAccessUID(item.UID) {
+				models.SendErrorResponse(c, http.StatusNotFound, "Batch not found")
+				return
+			}
+		}
+	}
+
+	models.SendResponseData(c, gin.H{"batch": batch})
+}
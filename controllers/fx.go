@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"ledger-service/services"
+)
+
+// ListFxRates lists the FX rates.
+// @Summary List FX rates.
+// @Description List the mid-market rate, spread and source of every currency pair
+// @Tags FX
+// @Produce  json
+// @Success 200 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /fx/rates [get]
+func ListFxRates(c *gin.Context) {
+	rates, err := services.ListFxRates(c)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"rates": rates})
+}
+
+// SetFxRates creates or replaces FX rates.
+// @Summary Set FX rates.
+// @Description Create or replace the rates of currency pairs. Rates of pairs that are not listed are kept.
+// @Tags FX
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param requestBody body models.SetFxRatesRequest true "Set FX Rates Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Router /admin/fx_rates [put]
+func SetFxRates(c *gin.Context) {
+	var requestBody models.SetFxRatesRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	rates, err := services.SetFxRates(c, requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"rates": rates})
+}
+
+// CreateFxQuote quotes a conversion between two wallets of a user.
+// @Summary Quote a currency conversion.
+// @Description Price a conversion from a wallet of a user to another of its wallets in a different currency. A conversion made with the quote_id before expires_at gets exactly this price.
+// @Tags FX
+// @Accept  json
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Param wallet path string true "Wallet name"
+// @Param requestBody body models.ConvertFundsRequest true "Destination wallet and amount"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/wallets/{wallet}/quotes [post]
+func CreateFxQuote(c *gin.Context) {
+	var requestBody models.ConvertFundsRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	quote, err := services.CreateFxQuote(c, c.Param("uid"), c.Param("wallet"), requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"quote": quote})
+}
+
+// ConvertFunds converts funds between two wallets of a user.
+// @Summary Convert funds to another currency.
+// @Description Debit a wallet of a user and credit another of its wallets in a different currency, at the rate of the quote when quote_id is set or else at the current rate. The conversion fee, if any, is charged to the source wallet on top of the amount.
+// @Tags FX
+// @Accept  json
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Param wallet path string true "Wallet name"
+// @Param requestBody body models.ConvertFundsRequest true "Destination wallet, amount and quote"
+// @Success 200 {object} models.Response
+// @Success 202 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 422 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/wallets/{wallet}/convert [post]
+func ConvertFunds(c *gin.Context) {
+	uid := c.Param("uid")
+	var requestBody models.ConvertFundsRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	posting, err := services.ConvertFunds(c, uid, walletParam(c), requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+	if posting.Hold != nil {
+		sendHeld(c, uid, posting.Hold)
+		return
+	}
+
+	response := gin.H{
+		"Id":         uid,
+		"Message":    "Funds converted successfully",
+		"conversion": posting.Conversion,
+	}
+	if posting.Fee != nil {
+		response["fee"] = posting.Fee
+	}
+	models.SendResponseData(c, response)
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+)
+
+// Healthz reports that the process is alive.
+// @Summary Liveness probe.
+// @Description Returns 200 as long as the process can serve HTTP. Dependencies are not checked.
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": models.HealthStatusUp})
+}
+
+// Readyz reports whether the instance can serve traffic.
+// @Summary Readiness probe.
+// @Description Checks Postgres, Redis when enabled and the migration status, with the latency of each check. Returns 503 when a dependency is down or the instance is shutting down.
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} models.Readiness
+// @Failure 503 {object} models.Readiness
+// @Router /readyz [get]
+func Readyz(c *gin.Context) {
+	readiness := services.CheckReadiness(c.Request.Context())
+
+	status := http.StatusOK
+	if readiness.Status != models.HealthStatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/services"
+	"net/http"
+)
+
+// JWKS publishes the token verification keys.
+// @Summary Get the JSON Web Key Set.
+// @Description Get the public keys used to sign access and refresh tokens so other services can verify them.
+// @Tags Tokens
+// @Produce  json
+// @Success 200 {object} models.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, services.JWKS())
+}
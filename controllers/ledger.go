@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"ledger-service/models"
 	"ledger-service/services"
 	"net/http"
@@ -10,81 +11,92 @@ import (
 
 // AddFunds adds funds to a user's account.
 // @Summary Add funds to a user's account
-// @Description Add funds to a user's account by the given UID and amount
+// @Description Add funds to a wallet of a user by the given UID and amount. Without a wallet the main wallet is credited.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param uid path string true "User ID"
+// @Param wallet path string false "Wallet name"
 // @Param AddFundsRequest body models.AddFundsRequest true "Amount to add"
 // @Success 201 {object} models.Response
-// @Failure 400 Bad Request models.Response
-// @Failure 409 Conflict models.Response
-// @Failure 500 Internal Server Error models.Response
+// @Success 202 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 422 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Failure 503 {object} models.Response
 // @Router /users/{uid}/add [post]
+// @Router /users/{uid}/wallets/{wallet}/add [post]
 func AddFunds(c *gin.Context) {
 	uid := c.Param("uid")
 	var request models.AddFundsRequest
-	if err := c.BindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		models.SendError(c, models.WrapError(models.ErrValidation, err.Error(), err))
 		return
 	}
 
-	message, err := services.AddFunds(c, uid, request)
+	posting, err := services.AddFunds(c, uid, walletParam(c), request)
 	if err != nil {
-		if err.Error() == "Amount must be positive" {
-			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
-		} else if err.Error() == "Transaction already processed" {
-			models.SendErrorResponse(c, http.StatusConflict, err.Error())
-		} else {
-			models.SendErrorResponse(c, http.StatusInternalServerError, err.Error())
-		}
+		models.SendError(c, err)
+		return
+	}
+	if posting.Hold != nil {
+		sendHeld(c, uid, posting.Hold)
 		return
 	}
 
 	// Return success response
-	models.SendResponseData(c, gin.H{
-		"Id":      uid,
-		"Message": message,
-	})
+	sendPosted(c, uid, "Funds added successfully", posting)
 }
 
 // GetBalance retrieves the balance of a user.
 // @Summary Get user's balance
-// @Description Get the balance of a user by the given UID
+// @Description Get the balance of a wallet of a user by the given UID. Without a wallet the balance of the main wallet is returned.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param uid path string true "User ID"
+// @Param wallet path string false "Wallet name"
 // @Success 200 {object} models.Response
 // @Failure 404 Not Found models.Response
+// @Failure 503 Service Unavailable models.Response
 // @Router /users/{uid}/balance [get]
+// @Router /users/{uid}/wallets/{wallet}/balance [get]
 func GetBalance(c *gin.Context) {
 	uid := c.Param("uid")
 
 	// Call the GetBalance service function
-	balance, err := services.GetBalance(c, uid)
+	balance, degraded, err := services.GetBalance(c, uid, walletParam(c))
 	if err != nil {
 		// If error occurs, send error response
-		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		models.SendError(c, err)
 		return
 	}
 
-	// If successful, send balance data in the response
-	models.SendResponseData(c, gin.H{"Balance": balance})
+	// If successful, send balance data in the response, flagging balances served from the stale cache
+	data := gin.H{"Balance": balance}
+	if degraded {
+		data["Degraded"] = true
+	}
+	models.SendResponseData(c, data)
 }
 
 // GetTransactionHistory retrieves the transaction history of a user.
 // @Summary Get user's transaction history
-// @Description Get the transaction history of a user by the given UID with pagination
+// @Description Get the transaction history of a wallet of a user by the given UID with pagination. Without a wallet the history of the main wallet is returned.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param uid path string true "User ID"
+// @Param wallet path string false "Wallet name"
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Limit per page" default(10)
 // @Success 200 {object} models.Response
 // @Failure 404 Not Found models.Response
+// @Failure 503 Service Unavailable models.Response
 // @Router /users/{uid}/transactions [get]
+// @Router /users/{uid}/wallets/{wallet}/history [get]
 func GetTransactionHistory(c *gin.Context) {
 	uid := c.Param("uid") // Get user ID from the path parameter
 
@@ -93,14 +105,84 @@ func GetTransactionHistory(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
 	// Call the GetTransactionHistory service function with the uid, page, and limit
-	transactions, err := services.GetTransactionHistory(c, uid, page, limit)
+	transactions, err := services.GetTransactionHistory(c, uid, walletParam(c), page, limit)
 
 	// If an error occurs, send an error response
 	if err != nil {
-		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		models.SendError(c, err)
 		return
 	}
 
 	// If successful, send the result with transactions and pagination information
 	models.SendResponseData(c, gin.H{"List of Transactions": transactions})
 }
+
+// MoveFunds moves funds between two wallets of a user.
+// @Summary Move funds between wallets
+// @Description Move funds from a wallet of a user to another of its wallets. The move fee, if any, is charged to the source wallet on top of the amount.
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param uid path string true "User ID"
+// @Param wallet path string true "Wallet name"
+// @Param MoveFundsRequest body models.MoveFundsRequest true "Destination wallet and amount"
+// @Success 200 {object} models.Response
+// @Success 202 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 422 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/wallets/{wallet}/move [post]
+func MoveFunds(c *gin.Context) {
+	uid := c.Param("uid")
+	var request models.MoveFundsRequest
+	_ = c.ShouldBindBodyWith(&request, binding.JSON)
+
+	posting, err := services.MoveFunds(c, uid, walletParam(c), request)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+	if posting.Hold != nil {
+		sendHeld(c, uid, posting.Hold)
+		return
+	}
+
+	sendPosted(c, uid, "Funds moved successfully", posting)
+}
+
+// sendPosted answers for a transaction that was written, with the breakdown of the fee when one was charged on it
+func sendPosted(c *gin.Context, uid string, message string, posting models.Posting) {
+	response := gin.H{
+		"Id":             uid,
+		"Message":        message,
+		"transaction_id": posting.TransactionID,
+	}
+	if posting.Fee != nil {
+		response["fee"] = posting.Fee
+	}
+	models.SendResponseData(c, response)
+}
+
+// sendHeld answers 202 Accepted for a transaction that a risk rule held for review
+func sendHeld(c *gin.Context, uid string, hold *models.RiskHold) {
+	response := &models.Response{
+		StatusCode: http.StatusAccepted,
+		Success:    true,
+		Data: gin.H{
+			"Id":      uid,
+			"Message": "Transaction is held for review",
+			"hold":    hold,
+		},
+	}
+	response.SendResponse(c)
+}
+
+// walletParam returns the wallet of the route, or the main wallet on routes without one
+func walletParam(c *gin.Context) string {
+	if wallet := c.Param("wallet"); wallet != "" {
+		return wallet
+	}
+	return models.MainWallet
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"ledger-service/services"
+)
+
+// GetLimits retrieves the limits of a user.
+// @Summary Get a user's limits.
+// @Description Get the overdraft limit of a user and the funds it leaves available in the main wallet
+// @Tags Users
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/limits [get]
+func GetLimits(c *gin.Context) {
+	limits, err := services.GetLimits(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"limits": limits})
+}
+
+// SetLimits changes the limits of a user.
+// @Summary Change a user's limits.
+// @Description Change the overdraft limit of a user with a note. Every change is recorded in the audit trail.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Param requestBody body models.SetLimitsRequest true "Set Limits Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 410 {object} models.Response
+// @Router /admin/users/{uid}/limits [put]
+func SetLimits(c *gin.Context) {
+	var requestBody models.SetLimitsRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	limits, err := services.SetLimits(c, c.Param("uid"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"limits": limits})
+}
+
+// ListLimitChanges lists the limit changes of a user.
+// @Summary List a user's limit changes.
+// @Description List the audit trail of limit changes of the account, newest first.
+// @Tags Users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Router /admin/users/{uid}/limit_changes [get]
+func ListLimitChanges(c *gin.Context) {
+	changes, err := services.ListLimitChanges(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"limit_changes": changes})
+}
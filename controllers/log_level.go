@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"net/http"
+)
+
+// GetLogLevel returns the current log level.
+// @Summary Get the log level.
+// @Description Get the minimum level of the entries written to the application log.
+// @Tags Logging
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Router /admin/log_level [get]
+func GetLogLevel(c *gin.Context) {
+	models.SendResponseData(c, gin.H{"level": logger.Level()})
+}
+
+// SetLogLevel changes the log level without a restart.
+// @Summary Change the log level.
+// @Description Change the minimum level of the entries written to the application log. The change is lost on restart.
+// @Tags Logging
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param requestBody body models.LogLevelRequest true "Log Level Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Router /admin/log_level [put]
+func SetLogLevel(c *gin.Context) {
+	var requestBody models.LogLevelRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	previous := logger.Level()
+	if err := logger.SetLevel(requestBody.Level); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.InfoCtx(c, "Log level changed", zap.String("from", previous), zap.String("to", logger.Level()))
+	models.SendResponseData(c, gin.H{"level": logger.Level()})
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+	"strconv"
+)
+
+// CreateRecurringTransfer schedules a recurring transfer between two wallets of a user.
+// @Summary Schedule a recurring transfer.
+// @Description Move an amount from a wallet of a user to another of its wallets every day, week or month, such as a weekly sweep to savings. The first transfer runs at start_at, or right away without it.
+// @Tags Wallets
+// @Accept  json
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Param wallet path string true "Wallet name"
+// @Param requestBody body models.CreateRecurringTransferRequest true "Create Recurring Transfer Request"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 422 {object} models.Response
+// @Router /users/{uid}/wallets/{wallet}/recurring_transfers [post]
+func CreateRecurringTransfer(c *gin.Context) {
+	var requestBody models.CreateRecurringTransferRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	transfer, err := services.CreateRecurringTransfer(c, c.Param("uid"), c.Param("wallet"), requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"recurring_transfer": transfer},
+	}
+	response.SendResponse(c)
+}
+
+// ListRecurringTransfers lists the recurring transfers of a user.
+// @Summary List a user's recurring transfers.
+// @Description List the recurring transfers of a user with their next run and the error of the last skipped run
+// @Tags Wallets
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/recurring_transfers [get]
+func ListRecurringTransfers(c *gin.Context) {
+	transfers, err := services.ListRecurringTransfers(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"recurring_transfers": transfers})
+}
+
+// DeleteRecurringTransfer cancels a recurring transfer of a user.
+// @Summary Cancel a recurring transfer.
+// @Description Stop a recurring transfer. Transfers it already made are kept.
+// @Tags Wallets
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Param id path int true "Recurring transfer ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Router /users/{uid}/recurring_transfers/{id} [delete]
+func DeleteRecurringTransfer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "Invalid recurring transfer ID")
+		return
+	}
+
+	if err := services.DeleteRecurringTransfer(c, c.Param("uid"), uint(id)); err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Message": "Recurring transfer deleted"})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+)
+
+// ListHolds lists the transactions held for review.
+// @Summary List held transactions.
+// @Description List the transactions held for review by a risk rule, oldest first. Pending holds are listed by default.
+// @Tags Risk
+// @Produce  json
+// @Param status query string false "Hold status" Enums(pending, approved, rejected) default(pending)
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Router /risk/holds [get]
+func ListHolds(c *gin.Context) {
+	status := c.DefaultQuery("status", models.HoldStatusPending)
+	if err := validation.Validate(status, validation.In(models.HoldStatuses...)); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "status "+err.Error())
+		return
+	}
+
+	holds, err := services.ListHolds(c, status)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"holds": holds})
+}
+
+// ApproveHold approves a held transaction.
+// @Summary Approve a held transaction.
+// @Description Write a held transaction. The account state, balance and velocity limits are checked again; when they reject it the hold stays pending.
+// @Tags Risk
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Transaction ID of the hold"
+// @Param requestBody body models.ReviewHoldRequest false "Review Hold Request"
+// @Success 200 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /risk/holds/{id}/approve [post]
+func ApproveHold(c *gin.Context) {
+	var requestBody models.ReviewHoldRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	hold, err := services.ApproveHold(c, c.Param("id"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"hold": hold})
+}
+
+// RejectHold rejects a held transaction.
+// @Summary Reject a held transaction.
+// @Description Reject a held transaction, which is then never written.
+// @Tags Risk
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Transaction ID of the hold"
+// @Param requestBody body models.ReviewHoldRequest false "Review Hold Request"
+// @Success 200 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /risk/holds/{id}/reject [post]
+func RejectHold(c *gin.Context) {
+	var requestBody models.ReviewHoldRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	hold, err := services.RejectHold(c, c.Param("id"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"hold": hold})
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/middlewares"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+)
+
+// CreateUser opens an account for a UID.
+// @Summary Create a user.
+// @Description Open an active account with a zero balance for the UID, with optional metadata. Funds can only be added to existing accounts.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Param requestBody body models.CreateUserRequest true "Create User Request"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /users [post]
+func CreateUser(c *gin.Context) {
+	var requestBody models.CreateUserRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	if principal := middlewares.GetPrincipal(c); principal != nil && !principal.CanAccessUID(requestBody.UID) {
+		models.SendErrorResponse(c, http.StatusForbidden, "api key is not allowed to access this uid")
+		return
+	}
+
+	user, err := services.CreateUser(c, requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"user": user},
+	}
+	response.SendResponse(c)
+}
+
+// GetUser retrieves the account of a user.
+// @Summary Get a user.
+// @Description Get the account of a user by the given UID, including its state and metadata
+// @Tags Users
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid} [get]
+func GetUser(c *gin.Context) {
+	user, err := services.GetUser(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"user": user})
+}
+
+// CreateWallet adds a wallet to the account of a user.
+// @Summary Create a wallet.
+// @Description Add an empty named wallet, such as savings or bonus, to the account of a user
+// @Tags Wallets
+// @Accept  json
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Param requestBody body models.CreateWalletRequest true "Create Wallet Request"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 410 {object} models.Response
+// @Router /users/{uid}/wallets [post]
+func CreateWallet(c *gin.Context) {
+	var requestBody models.CreateWalletRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	wallet, err := services.CreateWallet(c, c.Param("uid"), requestBody)
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"wallet": wallet},
+	}
+	response.SendResponse(c)
+}
+
+// ListWallets lists the wallets of a user.
+// @Summary List a user's wallets.
+// @Description List the wallets of a user with their balances, the main wallet first
+// @Tags Wallets
+// @Produce  json
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Router /users/{uid}/wallets [get]
+func ListWallets(c *gin.Context) {
+	wallets, err := services.ListWallets(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"wallets": wallets})
+}
+
+// FreezeUser freezes the account of a user.
+// @Summary Freeze a user's account.
+// @Description Stop debits from the account, and credits too when block_credits is set, with a reason code and an operator note. Every change is recorded in the audit trail.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Param requestBody body models.FreezeUserRequest true "Freeze User Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /admin/users/{uid}/freeze [post]
+func FreezeUser(c *gin.Context) {
+	var requestBody models.FreezeUserRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	user, err := services.FreezeUser(c, c.Param("uid"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"user": user})
+}
+
+// UnfreezeUser unfreezes the account of a user.
+// @Summary Unfreeze a user's account.
+// @Description Make a frozen account active again with a reason code and an operator note. Every change is recorded in the audit trail.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Param requestBody body models.UnfreezeUserRequest true "Unfreeze User Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /admin/users/{uid}/unfreeze [post]
+func UnfreezeUser(c *gin.Context) {
+	var requestBody models.UnfreezeUserRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	user, err := services.UnfreezeUser(c, c.Param("uid"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"user": user})
+}
+
+// CloseUser closes the account of a user.
+// @Summary Close a user's account.
+// @Description Close an account with a zero balance, or sweep its balance to the settlement account first when sweep_balance is set. Credits to a closed account answer 410 Gone; its balance and history stay readable.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Param requestBody body models.CloseUserRequest true "Close User Request"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 410 {object} models.Response
+// @Router /admin/users/{uid}/close [post]
+func CloseUser(c *gin.Context) {
+	var requestBody models.CloseUserRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	user, err := services.CloseUser(c, c.Param("uid"), requestBody, principalName(c))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"user": user})
+}
+
+// ListUserStatusChanges lists the state changes of the account of a user.
+// @Summary List a user's account state changes.
+// @Description List the audit trail of freezes, unfreezes and other state changes of the account, newest first.
+// @Tags Users
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param uid path string true "User ID"
+// @Success 200 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Router /admin/users/{uid}/status_changes [get]
+func ListUserStatusChanges(c *gin.Context) {
+	changes, err := services.ListUserStatusChanges(c, c.Param("uid"))
+	if err != nil {
+		models.SendError(c, err)
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"status_changes": changes})
+}
+
+// principalName returns the name of the caller recorded in audit trails
+func principalName(c *gin.Context) string {
+	if principal := middlewares.GetPrincipal(c); principal != nil {
+		return principal.Name
+	}
+	return "unknown"
+}
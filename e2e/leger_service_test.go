@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"ledger-service/models"
 	"ledger-service/services"
 	"net/http"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 type addFundsResponse struct {
@@ -27,6 +29,20 @@ type getBalanceResponse struct {
 	} `json:"data"`
 }
 
+// authorizedRequest builds a request carrying an access token of an operator
+func authorizedRequest(t *testing.T, method string, target string, contentType string, body string) *http.Request {
+	accessToken, err := services.CreateToken("e2e@ledger.local", models.RoleOperator, models.TokenTypeAccess, "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Bearer-Token", accessToken.Token)
+	return req
+}
+
 func TestAddFunds(t *testing.T) {
 	services.LoadConfig()
 	services.ConnectDB()
@@ -37,8 +53,14 @@ func TestAddFunds(t *testing.T) {
 	// Use an existing user ID for testing
 	userID := "9f3a1d82c5e74e2b"
 
+	// Open the account unless an earlier run already did
+	createResp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodPost, "http://nginx:4000/v1/users", "application/json", fmt.Sprintf(`{"uid": "%s"}`, userID)))
+	require.NoError(t, err)
+	createResp.Body.Close()
+	require.Contains(t, []int{http.StatusCreated, http.StatusConflict}, createResp.StatusCode)
+
 	// Make an HTTP request to the API endpoint
-	resp, err := http.Post(fmt.Sprintf("http://nginx:4000/v1/users/%s/add", userID), "application/json", strings.NewReader(data))
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodPost, fmt.Sprintf("http://nginx:4000/v1/users/%s/add", userID), "application/json", data))
 	if err != nil {
 		t.Fatalf("Failed to make HTTP request: %s", err.Error())
 	}
@@ -83,7 +105,7 @@ func TestGetBalance(t *testing.T) {
 	userID := "9f3a1d82c5e74e2b"
 
 	// Make an HTTP request to the API endpoint
-	resp, err := http.Get(fmt.Sprintf("http://nginx:4000/v1/users/%s/balance", userID))
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodGet, fmt.Sprintf("http://nginx:4000/v1/users/%s/balance", userID), "", ""))
 	if err != nil {
 		t.Fatalf("Failed to make HTTP request: %s", err.Error())
 	}
@@ -118,7 +140,7 @@ func TestGetTransactionHistory(t *testing.T) {
 	services.ConnectDB()
 	userID := "9f3a1d82c5e74e2b"
 
-	resp, err := http.Get(fmt.Sprintf("http://nginx:4000/v1/users/%s/history", userID))
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodGet, fmt.Sprintf("http://nginx:4000/v1/users/%s/history", userID), "", ""))
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 	defer resp.Body.Close()
@@ -138,7 +160,7 @@ func TestAddFundsInvalidAmount(t *testing.T) {
 	services.LoadConfig()
 	services.ConnectDB()
 
-	resp, err := http.PostForm("http://nginx:4000/v1/users/9f3a1d82c5e74e2b/add", url.Values{"amount": {"-100"}})
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodPost, "http://nginx:4000/v1/users/9f3a1d82c5e74e2b/add", "application/x-www-form-urlencoded", url.Values{"amount": {"-100"}}.Encode()))
 	require.NoError(t, err)
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	defer resp.Body.Close()
@@ -151,14 +173,16 @@ func TestAddFundsInvalidAmount(t *testing.T) {
 	require.NoError(t, err)
 
 	require.NotNil(t, errorResponse)
-	require.NotEmpty(t, errorResponse["error"])
+	require.Equal(t, false, errorResponse["success"])
+	require.Equal(t, models.ErrorCodeValidation, errorResponse["code"])
+	require.NotEmpty(t, errorResponse["message"])
 }
 
 func TestGetBalanceNonExistentUser(t *testing.T) {
 	services.LoadConfig()
 	services.ConnectDB()
 
-	resp, err := http.Get("http://nginx:4000/v1/users/9999/balance")
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodGet, "http://nginx:4000/v1/users/9999/balance", "", ""))
 	require.NoError(t, err)
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 	defer resp.Body.Close()
@@ -174,3 +198,23 @@ func TestGetBalanceNonExistentUser(t *testing.T) {
 	require.NotNil(t, errorResponse)
 	require.Equal(t, expectedMessage, errorResponse["message"], "Expected error message: %s, got: %s", expectedMessage, errorResponse["message"])
 }
+
+func TestAddFundsUnknownUser(t *testing.T) {
+	services.LoadConfig()
+	services.ConnectDB()
+
+	resp, err := http.DefaultClient.Do(authorizedRequest(t, http.MethodPost, "http://nginx:4000/v1/users/unknown-e2e-user/add", "application/json", `{"amount": 100}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var errorResponse map[string]interface{}
+	err = json.Unmarshal(body, &errorResponse)
+	require.NoError(t, err)
+
+	require.Equal(t, models.ErrorCodeNotFound, errorResponse["code"])
+	require.Equal(t, "User not found", errorResponse["message"])
+}
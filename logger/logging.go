@@ -1,24 +1,94 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"os"
+)
+
+// Supported log formats
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
 )
 
 var zapLog *zap.Logger
 
+// accessLog writes one entry per served HTTP request
+var accessLog *zap.Logger
+
+// level is shared by every logger built by this package so it can be changed at runtime
+var level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
 func init() {
-	var err error
-	config := zap.NewProductionConfig()
-	enccoderConfig := zap.NewProductionEncoderConfig()
-	zapcore.TimeEncoderOfLayout("Jan _2 15:04:05.000000000")
-	enccoderConfig.StacktraceKey = "" // to hide stacktrace info
-	config.EncoderConfig = enccoderConfig
-
-	zapLog, err = config.Build(zap.AddCallerSkip(1))
+	zapLog = build(FormatJSON, zapcore.Lock(os.Stdout))
+	accessLog = buildAccess(FormatJSON, zapcore.Lock(os.Stdout))
+}
+
+// Configure replaces the logger with one writing entries of at least the level in the format to the output
+func Configure(logLevel string, format string, output zapcore.WriteSyncer) error {
+	if err := SetLevel(logLevel); err != nil {
+		return err
+	}
+	if format != FormatJSON && format != FormatConsole {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	zapLog = build(format, output)
+	return nil
+}
+
+// ConfigureAccess replaces the access logger with one writing entries in the format to the output.
+// Access entries are written regardless of the log level.
+func ConfigureAccess(format string, output zapcore.WriteSyncer) error {
+	if format != FormatJSON && format != FormatConsole {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	accessLog = buildAccess(format, output)
+	return nil
+}
+
+// Access writes an access log entry
+func Access(message string, fields ...zap.Field) {
+	accessLog.Info(message, fields...)
+}
+
+// SetLevel changes the minimum level of logged entries
+func SetLevel(logLevel string) error {
+	parsed, err := zapcore.ParseLevel(logLevel)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	level.SetLevel(parsed)
+	return nil
+}
+
+// Level returns the minimum level of logged entries
+func Level() string {
+	return level.String()
+}
+
+func build(format string, output zapcore.WriteSyncer) *zap.Logger {
+	return zap.New(zapcore.NewCore(newEncoder(format), output, level), zap.AddCaller(), zap.AddCallerSkip(1))
+}
+
+func buildAccess(format string, output zapcore.WriteSyncer) *zap.Logger {
+	return zap.New(zapcore.NewCore(newEncoder(format), output, zapcore.InfoLevel)).Named("access")
+}
+
+func newEncoder(format string) zapcore.Encoder {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.StacktraceKey = "" // to hide stacktrace info
+
+	if format == FormatConsole {
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
 }
 
 func Info(message string, fields ...zap.Field) {
@@ -36,3 +106,53 @@ func Error(message string, fields ...zap.Field) {
 func Fatal(message string, fields ...zap.Field) {
 	zapLog.Fatal(message, fields...)
 }
+
+// contextKey is the context key under which the request logger is stored
+type contextKey struct{}
+
+// WithFields returns a copy of the context whose logger adds the fields to every entry logged with it,
+// such as the request ID, UID, route or transaction ID
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return context.WithValue(ctx, contextKey{}, fromContext(ctx).With(fields...))
+}
+
+// fromContext returns the logger stored by WithFields, or the global logger
+func fromContext(ctx context.Context) *zap.Logger {
+	if ctxLog, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return ctxLog
+	}
+	return zapLog
+}
+
+// InfoCtx logs like Info with the fields of the context and the trace and span ID of its span
+func InfoCtx(ctx context.Context, message string, fields ...zap.Field) {
+	fromContext(ctx).Info(message, append(fields, traceFields(ctx)...)...)
+}
+
+// DebugCtx logs like Debug with the fields of the context and the trace and span ID of its span
+func DebugCtx(ctx context.Context, message string, fields ...zap.Field) {
+	fromContext(ctx).Debug(message, append(fields, traceFields(ctx)...)...)
+}
+
+// ErrorCtx logs like Error with the fields of the context and the trace and span ID of its span
+func ErrorCtx(ctx context.Context, message string, fields ...zap.Field) {
+	fromContext(ctx).Error(message, append(fields, traceFields(ctx)...)...)
+}
+
+// traceFields returns the trace and span ID of the span in the context, if there is one
+func traceFields(ctx context.Context) []zap.Field {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	}
+}
+
+// Sync flushes any buffered log entries
+func Sync() error {
+	_ = accessLog.Sync()
+	return zapLog.Sync()
+}
@@ -3,24 +3,37 @@ package main
 import (
 	"context"
 	"go.uber.org/zap"
+	"ledger-service/logger"
 	"ledger-service/routes"
 	"ledger-service/services"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 func main() {
-	logger, _ := zap.NewDevelopment()
 	defer logger.Sync()
 
 	services.LoadConfig()
+	services.ConfigureLogging()
+	services.InitTracing()
+	services.LoadSigningKeys()
+	services.LoadRoles()
+	services.LoadRequestSigningSecrets()
+	services.LoadRiskRules()
+	services.LoadFeeSchedules()
+	services.LoadInterestRates()
 	services.ConnectDB()
+	services.LoadFxRates()
 
 	if services.Config.UseRedis {
 		services.CheckRedisConnection()
 	}
 
+	services.StartScheduler()
+
 	routes.InitGin()
 	router := routes.New()
 
@@ -33,23 +46,38 @@ func main() {
 	}
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			logger.Error("listen", zap.Error(err))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("listen", zap.Error(err))
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shut down the server with
-	// a timeout of 15 seconds.
+	// Wait for SIGINT or SIGTERM to gracefully shut down the server
+	// within SHUTDOWN_TIMEOUT_SECONDS.
 	quit := make(chan os.Signal, 1)
-	//signal.Notify(quit, os.Interrupt)
-	<-quit
-	logger.Info("Shutdown Server")
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logger.Info("Shutdown Server", zap.String("signal", sig.String()))
+
+	// Report not ready and keep serving, writes included, for a moment so the load balancer stops routing here first
+	services.BeginShutdown()
+	services.StopScheduler()
+	time.Sleep(time.Duration(services.Config.ShutdownReadinessDelay) * time.Second)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(services.Config.ShutdownTimeoutSeconds)*time.Second)
 	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatal("Server Shutdown", zap.Error(err))
+		logger.Error("Server Shutdown", zap.Error(err))
 	}
 
+	// Wait for ledger writes that outlived their request; locks of writes still running expire on their own
+	if err := services.DrainLedgerWrites(ctx); err != nil {
+		logger.Error("Draining ledger writes", zap.Error(err))
+	}
+
+	services.ShutdownTracing(ctx)
+	services.CloseConnections()
+
 	logger.Info("Server exiting")
 }
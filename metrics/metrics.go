@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// APIKeyRequests counts requests authenticated with an API key, by key name
+var APIKeyRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_api_key_requests_total",
+		Help: "Number of requests authenticated with an API key",
+	},
+	[]string{"key", "route", "status"},
+)
+
+// HTTPRequests counts served requests by route template and status
+var HTTPRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_http_requests_total",
+		Help: "Number of served HTTP requests",
+	},
+	[]string{"method", "route", "status"},
+)
+
+// HTTPRequestDuration observes the time taken to serve a request by route template and status
+var HTTPRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "ledger_http_request_duration_seconds",
+		Help:    "Time taken to serve an HTTP request",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+	},
+	[]string{"method", "route", "status"},
+)
+
+// HTTPRequestsInFlight is the number of requests currently being served
+var HTTPRequestsInFlight = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "ledger_http_requests_in_flight",
+		Help: "Number of HTTP requests currently being served",
+	},
+)
+
+// FundsAdded counts successful credits by currency
+var FundsAdded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_funds_added_total",
+		Help: "Number of successful credits",
+	},
+	[]string{"currency"},
+)
+
+// FundsAddedAmount sums the amount of successful credits by currency
+var FundsAddedAmount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_funds_added_amount_total",
+		Help: "Total amount of successful credits",
+	},
+	[]string{"currency"},
+)
+
+// LockWaitDuration observes the time spent acquiring a distributed lock
+var LockWaitDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "ledger_lock_wait_duration_seconds",
+		Help:    "Time spent acquiring a distributed lock",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0},
+	},
+	[]string{"lock"},
+)
+
+// LockFailures counts distributed locks that could not be acquired
+var LockFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_lock_failures_total",
+		Help: "Number of distributed locks that could not be acquired",
+	},
+	[]string{"lock"},
+)
+
+// CacheRequests counts cache lookups by result (hit, miss or error); the hit ratio is hit / sum over results
+var CacheRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_cache_requests_total",
+		Help: "Number of cache lookups by result",
+	},
+	[]string{"cache", "result"},
+)
+
+// DBQueryDuration observes the latency of database operations
+var DBQueryDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "ledger_db_query_duration_seconds",
+		Help:    "Latency of database operations",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0},
+	},
+	[]string{"operation", "table"},
+)
+
+// TokensIssued counts issued JWTs by token type
+var TokensIssued = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_tokens_issued_total",
+		Help: "Number of issued tokens",
+	},
+	[]string{"type"},
+)
+
+// TokenFailures counts failed token issuance and verification by token type
+var TokenFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_token_failures_total",
+		Help: "Number of failed token issuances and verifications",
+	},
+	[]string{"type", "operation"},
+)
+
+// LimitRejections counts credits and debits rejected by a velocity limit, by limit
+var LimitRejections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_limit_rejections_total",
+		Help: "Number of credits and debits rejected by a velocity limit",
+	},
+	[]string{"limit"},
+)
+
+// RiskDecisions counts transactions denied or held by a risk rule, by rule and action
+var RiskDecisions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_risk_decisions_total",
+		Help: "Number of transactions denied or held by a risk rule",
+	},
+	[]string{"rule", "action"},
+)
+
+// FeesCharged sums the fees charged, by the transaction type they were charged on
+var FeesCharged = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_fees_charged_amount_total",
+		Help: "Total amount of fees charged",
+	},
+	[]string{"type"},
+)
+
+// ScheduledJobRuns counts runs of scheduled jobs, by job and result
+var ScheduledJobRuns = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_scheduled_job_runs_total",
+		Help: "Number of scheduled job runs",
+	},
+	[]string{"job", "result"},
+)
+
+// ScheduledJobDuration observes how long scheduled jobs run, by job
+var ScheduledJobDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "ledger_scheduled_job_duration_seconds",
+		Help:    "Duration of scheduled job runs",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1.0, 5.0, 10.0, 30.0, 60.0, 300.0},
+	},
+	[]string{"job"},
+)
+
+// BatchItems counts the items of batches, by transaction type and result
+var BatchItems = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ledger_batch_items_total",
+		Help: "Number of batch items processed",
+	},
+	[]string{"type", "status"},
+)
+
+// Cache results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Token operations
+const (
+	TokenIssue  = "issue"
+	TokenVerify = "verify"
+)
+
+func init() {
+	prometheus.MustRegister(
+		APIKeyRequests,
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		FundsAdded,
+		FundsAddedAmount,
+		LockWaitDuration,
+		LockFailures,
+		CacheRequests,
+		DBQueryDuration,
+		TokensIssued,
+		TokenFailures,
+		LimitRejections,
+		RiskDecisions,
+		FeesCharged,
+		ScheduledJobRuns,
+		ScheduledJobDuration,
+		BatchItems,
+	)
+}
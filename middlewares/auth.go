@@ -2,17 +2,25 @@ package middlewares
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/metrics"
 	"ledger-service/models"
 	"ledger-service/services"
 	"net/http"
-	"time"
+	"strconv"
 )
 
+// APIKeyHeader carries the API key of service-to-service callers
+const APIKeyHeader = "X-API-Key"
+
+// PrincipalKey is the context key under which the authenticated *models.Principal is stored
+const PrincipalKey = "principal"
+
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Bearer-Token")
-		tokenModel, err := services.VerifyToken(token, models.TokenTypeAccess)
+		tokenModel, claims, err := services.VerifyToken(token, models.TokenTypeAccess)
 		if err != nil {
 			models.SendErrorResponse(c, http.StatusUnauthorized, err.Error())
 			return
@@ -20,25 +28,80 @@ func JWTMiddleware() gin.HandlerFunc {
 
 		c.Set("userIdHex", tokenModel.ID)
 		c.Set("userId", tokenModel.ID)
+		c.Set(PrincipalKey, &models.Principal{
+			Type:   models.PrincipalTypeUser,
+			Name:   claims.Email,
+			Scopes: claims.Scopes,
+		})
 
 		c.Next()
 	}
 }
 
-func ResponseTimeMiddleware() gin.HandlerFunc {
+// APIKeyMiddleware authenticates service callers by the key in the X-API-Key header.
+// Keys with a UID allow-list are rejected on routes for other UIDs.
+// Every request made with a key is logged and counted by key name.
+func APIKeyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		responseTime := prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "response_time_seconds",
-				Help:    "Time taken to serve a request",
-				Buckets: []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0},
-			},
-			[]string{"method", "endpoint"},
+		apiKey, err := services.VerifyAPIKey(c.GetHeader(APIKeyHeader))
+		if err != nil {
+			logger.InfoCtx(c, "API key rejected", zap.Error(err))
+			models.SendErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		principal := apiKey.Principal()
+		if uid := c.Param("uid"); uid != "" && !principal.CanAccessUID(uid) {
+			models.SendErrorResponse(c, http.StatusForbidden, "api key is not allowed to access this uid")
+		} else {
+			c.Set(PrincipalKey, principal)
+			c.Next()
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.APIKeyRequests.WithLabelValues(apiKey.Name, c.FullPath(), status).Inc()
+		logger.InfoCtx(c, "API key request",
+			zap.String("api_key", apiKey.Name),
+			zap.String("method", c.Request.Method),
+			zap.String("status", status),
 		)
+	}
+}
+
+// AuthMiddleware accepts either an API key or a JWT access token, preferring the API key when both are sent
+func AuthMiddleware() gin.HandlerFunc {
+	apiKeyMiddleware := APIKeyMiddleware()
+	jwtMiddleware := JWTMiddleware()
+
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "" {
+			apiKeyMiddleware(c)
+			return
+		}
+		jwtMiddleware(c)
+	}
+}
+
+// RequireScope rejects callers whose principal was not granted the scope.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.HasScope(scope) {
+			models.SendErrorResponse(c, http.StatusForbidden, "missing scope "+scope)
+			return
+		}
 
-		start := time.Now()
 		c.Next()
-		elapsed := time.Since(start).Seconds()
-		responseTime.WithLabelValues(c.Request.Method, c.FullPath()).Observe(elapsed)
 	}
 }
+
+// GetPrincipal returns the caller authenticated by AuthMiddleware, or nil for anonymous requests
+func GetPrincipal(c *gin.Context) *models.Principal {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*models.Principal)
+	return principal
+}
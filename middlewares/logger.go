@@ -1,19 +1,114 @@
 package middlewares
 
 import (
-	"io"
-	"os"
-	"path"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
-const LogPath = "logs/"
-const LogFile = "access.log"
+// redacted replaces the value of secrets in access log entries
+const redacted = "REDACTED"
 
-func LogWriter() io.Writer {
-	_ = os.Mkdir(LogPath, 0770)
+// sensitiveHeaders are never written to the access log in clear text
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Bearer-Token",
+	APIKeyHeader,
+	SignatureHeader,
+}
+
+// sensitiveQueryParams contains fragments of query parameter names whose values are redacted, like access_token or api_key
+var sensitiveQueryParams = []string{"token", "key", "secret", "password", "signature"}
+
+// AccessLogMiddleware writes one structured access log entry per request.
+// The client IP honours X-Forwarded-For only from TRUSTED_PROXIES, and secrets in the query string and headers are redacted.
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", redactQuery(c.Request.URL.RawQuery)),
+			zap.Int("status", c.Writer.Status()),
+			zap.Float64("latency_ms", float64(latency.Microseconds())/1000),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_agent", c.Request.UserAgent()),
+			zap.String("request_id", c.GetString(RequestIDKey)),
+			zap.Any("headers", redactHeaders(c.Request.Header)),
+		}
+		if uid := c.Param("uid"); uid != "" {
+			fields = append(fields, zap.String("uid", uid))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		logger.Access("request", fields...)
+	}
+}
 
-	logFilePath := path.Join(LogPath, LogFile)
+// redactQuery replaces the values of sensitive query parameters
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+	for name := range values {
+		if isSensitiveQueryParam(name) {
+			values[name] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
+
+func isSensitiveQueryParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range sensitiveQueryParams {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns the request headers with the values of sensitive headers replaced
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if isSensitiveHeader(name) {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
 
-	logFile, _ := os.OpenFile(logFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0660)
-	return io.MultiWriter(logFile, os.Stdout)
+func isSensitiveHeader(name string) bool {
+	for _, sensitive := range sensitiveHeaders {
+		if strings.EqualFold(name, sensitive) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/metrics"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests that did not match any route, so unknown paths cannot blow up the label cardinality
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records the count, duration and in-flight number of requests by route template and status
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start).Seconds()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(elapsed)
+	}
+}
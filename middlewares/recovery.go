@@ -12,6 +12,6 @@ func AppRecovery() func(c *gin.Context, recovered interface{}) {
 			models.SendErrorResponse(c, http.StatusInternalServerError, err)
 			return
 		}
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false}) // recovery failed
+		models.SendErrorResponse(c, http.StatusInternalServerError, "Internal Server Error") // recovery failed
 	}
 }
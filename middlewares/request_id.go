@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"regexp"
+)
+
+// RequestIDHeader carries the ID that correlates a request across services and log entries
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the context key under which the request ID is stored
+const RequestIDKey = "requestId"
+
+// requestIDPattern limits accepted request IDs so a caller cannot inject arbitrary content into the logs
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware accepts the X-Request-ID of the caller or generates one, and returns it in the response.
+// The request ID, route and UID are added to every entry logged with the request context.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		fields := []zap.Field{zap.String("request_id", requestID), zap.String("route", c.FullPath())}
+		if uid := c.Param("uid"); uid != "" {
+			fields = append(fields, zap.String("uid", uid))
+		}
+		ctx := logger.WithFields(c.Request.Context(), fields...)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
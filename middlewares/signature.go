@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"io"
+	"ledger-service/models"
+	"ledger-service/services"
+	"net/http"
+)
+
+// Headers of a signed request
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureClientHeader    = "X-Signature-Client"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+)
+
+// SignatureMiddleware verifies the X-Signature header of write requests.
+// Unsigned requests are only accepted while REQUEST_SIGNING_REQUIRED is off; a signature that is sent is always verified.
+func SignatureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signature := c.GetHeader(SignatureHeader)
+		if signature == "" {
+			if services.Config.RequestSigningRequired {
+				models.SendErrorResponse(c, http.StatusUnauthorized, "request signature is required")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, "cannot read request body")
+			return
+		}
+		// Restore the body for the handlers that bind it
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		err = services.VerifyRequestSignature(c, services.SignedRequest{
+			ClientID:  c.GetHeader(SignatureClientHeader),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Timestamp: c.GetHeader(SignatureTimestampHeader),
+			Nonce:     c.GetHeader(SignatureNonceHeader),
+			Body:      body,
+			Signature: signature,
+		})
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+	"ledger-service/services"
+)
+
+// TracingMiddleware starts a server span for every request, continuing the trace from an incoming traceparent header.
+// The span is stored in the request context so every span created while serving the request becomes its child.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		ctx, span := services.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.ServerRequest("", c.Request)...),
+			trace.WithAttributes(semconv.HTTPRoute(route)),
+		)
+		defer span.End()
+
+		if uid := c.Param("uid"); uid != "" {
+			span.SetAttributes(attribute.String("ledger.uid", uid))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		code, description := httpconv.ServerStatus(status)
+		span.SetStatus(code, description)
+		if len(c.Errors) > 0 {
+			span.SetAttributes(attribute.String("gin.errors", c.Errors.String()))
+		}
+	}
+}
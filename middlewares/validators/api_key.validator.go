@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func CreateAPIKeyValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var createAPIKeyRequest models.CreateAPIKeyRequest
+		_ = c.ShouldBindBodyWith(&createAPIKeyRequest, binding.JSON)
+
+		if err := createAPIKeyRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func CreateBatchValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var createBatchRequest models.CreateBatchRequest
+		_ = c.ShouldBindBodyWith(&createBatchRequest, binding.JSON)
+
+		if err := createBatchRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
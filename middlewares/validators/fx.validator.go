@@ -0,0 +1,38 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func ConvertFundsValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var convertFundsRequest models.ConvertFundsRequest
+		_ = c.ShouldBindBodyWith(&convertFundsRequest, binding.JSON)
+
+		if err := convertFundsRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func SetFxRatesValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var setFxRatesRequest models.SetFxRatesRequest
+		_ = c.ShouldBindBodyWith(&setFxRatesRequest, binding.JSON)
+
+		if err := setFxRatesRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func SetLimitsValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var setLimitsRequest models.SetLimitsRequest
+		_ = c.ShouldBindBodyWith(&setLimitsRequest, binding.JSON)
+
+		if err := setLimitsRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
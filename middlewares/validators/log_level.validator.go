@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func LogLevelValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var logLevelRequest models.LogLevelRequest
+		_ = c.ShouldBindBodyWith(&logLevelRequest, binding.JSON)
+
+		if err := logLevelRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func ReviewHoldValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var reviewHoldRequest models.ReviewHoldRequest
+		_ = c.ShouldBindBodyWith(&reviewHoldRequest, binding.JSON)
+
+		if err := reviewHoldRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"ledger-service/models"
+	"net/http"
+)
+
+func CreateUserValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var createUserRequest models.CreateUserRequest
+		_ = c.ShouldBindBodyWith(&createUserRequest, binding.JSON)
+
+		if err := createUserRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func FreezeUserValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var freezeUserRequest models.FreezeUserRequest
+		_ = c.ShouldBindBodyWith(&freezeUserRequest, binding.JSON)
+
+		if err := freezeUserRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func UnfreezeUserValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var unfreezeUserRequest models.UnfreezeUserRequest
+		_ = c.ShouldBindBodyWith(&unfreezeUserRequest, binding.JSON)
+
+		if err := unfreezeUserRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CloseUserValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var closeUserRequest models.CloseUserRequest
+		_ = c.ShouldBindBodyWith(&closeUserRequest, binding.JSON)
+
+		if err := closeUserRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateWalletValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var createWalletRequest models.CreateWalletRequest
+		_ = c.ShouldBindBodyWith(&createWalletRequest, binding.JSON)
+
+		if err := createWalletRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func MoveFundsValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var moveFundsRequest models.MoveFundsRequest
+		_ = c.ShouldBindBodyWith(&moveFundsRequest, binding.JSON)
+
+		if err := moveFundsRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateRecurringTransferValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var createRecurringTransferRequest models.CreateRecurringTransferRequest
+		_ = c.ShouldBindBodyWith(&createRecurringTransferRequest, binding.JSON)
+
+		if err := createRecurringTransferRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
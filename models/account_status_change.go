@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Reason codes of an account state change
+const (
+	ReasonSuspectedFraud   = "suspected_fraud"
+	ReasonComplianceReview = "compliance_review"
+	ReasonSanctionsMatch   = "sanctions_match"
+	ReasonCourtOrder       = "court_order"
+	ReasonCustomerRequest  = "customer_request"
+	ReasonReviewCleared    = "review_cleared"
+	ReasonOther            = "other"
+)
+
+// ReasonCodes lists every reason code an account state change can be recorded with
+var ReasonCodes = []interface{}{
+	ReasonSuspectedFraud, ReasonComplianceReview, ReasonSanctionsMatch, ReasonCourtOrder,
+	ReasonCustomerRequest, ReasonReviewCleared, ReasonOther,
+}
+
+// AccountStatusChange is the audit record of a change to the state of a user account
+type AccountStatusChange struct {
+	gorm.Model
+	UserID         uint   `json:"-" gorm:"index;not null"`
+	UID            string `json:"uid" gorm:"index;not null"`
+	FromStatus     string `json:"from_status" gorm:"type:varchar(16);not null"`
+	ToStatus       string `json:"to_status" gorm:"type:varchar(16);not null"`
+	CreditsBlocked bool   `json:"credits_blocked"`
+	ReasonCode     string `json:"reason_code" gorm:"not null"`
+	Note           string `json:"note"`
+	Actor          string `json:"actor" gorm:"not null"`
+}
+
+func (AccountStatusChange) TableName() string {
+	return "account_status_changes"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"time"
+)
+
+// APIKey authenticates a backend service. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	gorm.Model
+	Name        string         `json:"name" gorm:"unique;not null"`
+	Prefix      string         `json:"prefix" gorm:"not null"`
+	KeyHash     string         `json:"-" gorm:"unique;not null"`
+	Scopes      pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	AllowedUIDs pq.StringArray `json:"allowed_uids" gorm:"type:text[]"`
+	ExpiresAt   *time.Time     `json:"expires_at"`
+	RevokedAt   *time.Time     `json:"revoked_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Principal returns the caller identity granted by the key
+func (model APIKey) Principal() *Principal {
+	return &Principal{
+		Type:        PrincipalTypeAPIKey,
+		Name:        model.Name,
+		Scopes:      model.Scopes,
+		AllowedUIDs: model.AllowedUIDs,
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Modes of a batch. Atomic batches post every item or none; best-effort batches post the items that pass on their own.
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+// BatchModes lists every mode a batch can be processed in
+var BatchModes = []interface{}{BatchModeAtomic, BatchModeBestEffort}
+
+// States of a batch
+const (
+	BatchStatusProcessing      = "processing"
+	BatchStatusCompleted       = "completed"
+	BatchStatusPartiallyFailed = "partially_failed"
+	BatchStatusFailed          = "failed"
+)
+
+// States of an item of a batch. Duplicates were already posted under their idempotency key,
+// and skipped items were not posted because another item of an atomic batch failed.
+const (
+	BatchItemStatusPending   = "pending"
+	BatchItemStatusPosted    = "posted"
+	BatchItemStatusHeld      = "held"
+	BatchItemStatusDuplicate = "duplicate"
+	BatchItemStatusFailed    = "failed"
+	BatchItemStatusSkipped   = "skipped"
+)
+
+// BatchItemTypes lists the transaction types an item of a batch can post
+var BatchItemTypes = []interface{}{TransactionTypeCredit, TransactionTypeDebit}
+
+// Batch is a set of credits and debits submitted in one request and posted under one lock per wallet
+type Batch struct {
+	gorm.Model
+	Mode      string      `json:"mode" gorm:"type:varchar(16);not null"`
+	Status    string      `json:"status" gorm:"type:varchar(16);index;not null"`
+	CreatedBy string      `json:"created_by"`
+	ItemCount int         `json:"item_count"`
+	Posted    int         `json:"posted"`
+	Held      int         `json:"held"`
+	Duplicate int         `json:"duplicate"`
+	Failed    int         `json:"failed"`
+	Items     []BatchItem `json:"items,omitempty"`
+}
+
+func (Batch) TableName() string {
+	return "batches"
+}
+
+// BatchItem is one credit or debit of a batch and its result. It is posted as the transaction "batch:<idempotency key>",
+// so an item submitted again in any batch is reported as a duplicate instead of being posted twice.
+type BatchItem struct {
+	gorm.Model
+	BatchID        uint    `json:"-" gorm:"index;not null"`
+	Position       int     `json:"position"`
+	IdempotencyKey string  `json:"idempotency_key" gorm:"index;not null"`
+	UID            string  `json:"uid" gorm:"not null"`
+	Wallet         string  `json:"wallet" gorm:"not null"`
+	Type           string  `json:"type" gorm:"type:varchar(16);not null"`
+	Amount         float64 `json:"amount"`
+	Status         string  `json:"status" gorm:"type:varchar(16);not null"`
+	TransactionID  string  `json:"transaction_id"`
+	Fee            float64 `json:"fee,omitempty"`
+	ErrorCode      string  `json:"error_code,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	// Metadata is evaluated by the risk rules and kept with holds, but not stored with the item
+	Metadata map[string]string `json:"-" gorm:"-"`
+}
+
+func (BatchItem) TableName() string {
+	return "batch_items"
+}
@@ -6,20 +6,58 @@ import (
 )
 
 type EnvConfig struct {
-	DBHost                     string `mapstructure:"POSTGRES_HOST"`
-	DBUserName                 string `mapstructure:"POSTGRES_USER"`
-	DBUserPassword             string `mapstructure:"POSTGRES_PASSWORD"`
-	DBName                     string `mapstructure:"POSTGRES_DB"`
-	DBPort                     string `mapstructure:"POSTGRES_PORT"`
-	ServerHost                 string `mapstructure:"SERVER_HOST"`
-	ServerPort                 string `mapstructure:"SERVER_PORT"`
-	UseRedis                   bool   `mapstructure:"USE_REDIS"`
-	RedisDefaultAddr           string `mapstructure:"REDIS_DEFAULT_ADDR"`
-	RedisPassword              string `mapstructure:"REDIS_PASSWORD"`
-	JWTSecretKey               string `mapstructure:"JWT_SECRET"`
-	JWTAccessExpirationMinutes int    `mapstructure:"JWT_ACCESS_EXPIRATION_MINUTES"`
-	JWTRefreshExpirationDays   int    `mapstructure:"JWT_REFRESH_EXPIRATION_DAYS"`
-	Mode                       string `mapstructure:"MODE"`
+	DBHost                      string   `mapstructure:"POSTGRES_HOST"`
+	DBUserName                  string   `mapstructure:"POSTGRES_USER"`
+	DBUserPassword              string   `mapstructure:"POSTGRES_PASSWORD"`
+	DBName                      string   `mapstructure:"POSTGRES_DB"`
+	DBPort                      string   `mapstructure:"POSTGRES_PORT"`
+	ServerHost                  string   `mapstructure:"SERVER_HOST"`
+	ServerPort                  string   `mapstructure:"SERVER_PORT"`
+	UseRedis                    bool     `mapstructure:"USE_REDIS"`
+	RedisDefaultAddr            string   `mapstructure:"REDIS_DEFAULT_ADDR"`
+	RedisPassword               string   `mapstructure:"REDIS_PASSWORD"`
+	JWTSecretKey                string   `mapstructure:"JWT_SECRET"`
+	JWTKeyID                    string   `mapstructure:"JWT_KEY_ID"`
+	JWTKeyFiles                 []string `mapstructure:"JWT_KEY_FILES"`
+	JWTAccessExpirationMinutes  int      `mapstructure:"JWT_ACCESS_EXPIRATION_MINUTES"`
+	JWTRefreshExpirationDays    int      `mapstructure:"JWT_REFRESH_EXPIRATION_DAYS"`
+	AdminAPIKey                 string   `mapstructure:"ADMIN_API_KEY"`
+	AuthRoles                   []string `mapstructure:"AUTH_ROLES"`
+	AuthDefaultRole             string   `mapstructure:"AUTH_DEFAULT_ROLE"`
+	RequestSigningRequired      bool     `mapstructure:"REQUEST_SIGNING_REQUIRED"`
+	RequestSigningSecrets       []string `mapstructure:"REQUEST_SIGNING_SECRETS"`
+	RequestSigningMaxSkew       int      `mapstructure:"REQUEST_SIGNING_MAX_SKEW_SECONDS"`
+	AutoCreateUsers             bool     `mapstructure:"AUTO_CREATE_USERS"`
+	SettlementAccountUID        string   `mapstructure:"SETTLEMENT_ACCOUNT_UID"`
+	LimitMaxTransactionAmount   float64  `mapstructure:"LIMIT_MAX_TRANSACTION_AMOUNT"`
+	LimitMaxDailyCredit         float64  `mapstructure:"LIMIT_MAX_DAILY_CREDIT"`
+	LimitMaxWeeklyCredit        float64  `mapstructure:"LIMIT_MAX_WEEKLY_CREDIT"`
+	LimitMaxDailyDebit          float64  `mapstructure:"LIMIT_MAX_DAILY_DEBIT"`
+	LimitMaxTransactionsPerHour int      `mapstructure:"LIMIT_MAX_TRANSACTIONS_PER_HOUR"`
+	RiskRulesFile               string   `mapstructure:"RISK_RULES_FILE"`
+	FeeScheduleFile             string   `mapstructure:"FEE_SCHEDULE_FILE"`
+	FeeAccountUID               string   `mapstructure:"FEE_ACCOUNT_UID"`
+	InterestRates               []string `mapstructure:"INTEREST_RATES"`
+	FxRatesFile                 string   `mapstructure:"FX_RATES_FILE"`
+	FxQuoteTTLSeconds           int      `mapstructure:"FX_QUOTE_TTL_SECONDS"`
+	BatchMaxItems               int      `mapstructure:"BATCH_MAX_ITEMS"`
+	SchedulerEnabled            bool     `mapstructure:"SCHEDULER_ENABLED"`
+	SchedulerIntervalSeconds    int      `mapstructure:"SCHEDULER_INTERVAL_SECONDS"`
+	Mode                        string   `mapstructure:"MODE"`
+	ShutdownTimeoutSeconds      int      `mapstructure:"SHUTDOWN_TIMEOUT_SECONDS"`
+	ShutdownReadinessDelay      int      `mapstructure:"SHUTDOWN_READINESS_DELAY_SECONDS"`
+	TracingExporter             string   `mapstructure:"TRACING_EXPORTER"`
+	TracingFile                 string   `mapstructure:"TRACING_FILE"`
+	TracingSampleRatio          float64  `mapstructure:"TRACING_SAMPLE_RATIO"`
+	LogLevel                    string   `mapstructure:"LOG_LEVEL"`
+	LogFormat                   string   `mapstructure:"LOG_FORMAT"`
+	LogOutput                   string   `mapstructure:"LOG_OUTPUT"`
+	AccessLogFile               string   `mapstructure:"ACCESS_LOG_FILE"`
+	LogMaxSizeMB                int      `mapstructure:"LOG_MAX_SIZE_MB"`
+	LogMaxAgeDays               int      `mapstructure:"LOG_MAX_AGE_DAYS"`
+	LogMaxBackups               int      `mapstructure:"LOG_MAX_BACKUPS"`
+	LogCompress                 bool     `mapstructure:"LOG_COMPRESS"`
+	TrustedProxies              []string `mapstructure:"TRUSTED_PROXIES"`
 }
 
 func (config *EnvConfig) Validate() error {
@@ -31,10 +69,69 @@ func (config *EnvConfig) Validate() error {
 		validation.Field(&config.UseRedis, validation.In(true, false)),
 		validation.Field(&config.RedisDefaultAddr),
 
-		validation.Field(&config.JWTSecretKey, validation.Required),
+		validation.Field(&config.JWTSecretKey, validation.By(config.requiredWithoutKeyID)),
 		validation.Field(&config.JWTAccessExpirationMinutes, validation.Required),
 		validation.Field(&config.JWTRefreshExpirationDays, validation.Required),
+		validation.Field(&config.AdminAPIKey, validation.Length(32, 0)),
+		validation.Field(&config.AuthDefaultRole, validation.In(Roles...)),
+		validation.Field(&config.RequestSigningSecrets, validation.By(config.requiredWithSigning)),
+		validation.Field(&config.RequestSigningMaxSkew, validation.Required, validation.Min(1)),
+
+		validation.Field(&config.LimitMaxTransactionAmount, validation.Min(0.0)),
+		validation.Field(&config.LimitMaxDailyCredit, validation.Min(0.0)),
+		validation.Field(&config.LimitMaxWeeklyCredit, validation.Min(0.0)),
+		validation.Field(&config.LimitMaxDailyDebit, validation.Min(0.0)),
+		validation.Field(&config.LimitMaxTransactionsPerHour, validation.Min(0)),
+		validation.Field(&config.FeeAccountUID, validation.By(config.requiredWithFees)),
+		validation.Field(&config.SchedulerIntervalSeconds, validation.Required, validation.Min(1)),
+		validation.Field(&config.FxQuoteTTLSeconds, validation.Required, validation.Min(1)),
+		validation.Field(&config.BatchMaxItems, validation.Required, validation.Min(1)),
 
 		validation.Field(&config.Mode, validation.In("debug", "release")),
+		validation.Field(&config.ShutdownTimeoutSeconds, validation.Required, validation.Min(1)),
+		validation.Field(&config.ShutdownReadinessDelay, validation.Min(0)),
+		validation.Field(&config.TracingExporter, validation.In("none", "otlp", "file")),
+		validation.Field(&config.TracingFile, validation.By(config.requiredWithFileExporter)),
+		validation.Field(&config.TracingSampleRatio, validation.Min(0.0), validation.Max(1.0)),
+
+		validation.Field(&config.LogLevel, validation.In("debug", "info", "warn", "error")),
+		validation.Field(&config.LogFormat, validation.In("json", "console")),
+		validation.Field(&config.LogOutput, validation.Required),
+		validation.Field(&config.AccessLogFile, validation.Required),
+		validation.Field(&config.LogMaxSizeMB, validation.Required, validation.Min(1)),
+		validation.Field(&config.LogMaxAgeDays, validation.Min(0)),
+		validation.Field(&config.LogMaxBackups, validation.Min(0)),
 	)
 }
+
+// requiredWithSigning requires client secrets when every write request has to be signed
+func (config *EnvConfig) requiredWithSigning(value interface{}) error {
+	if !config.RequestSigningRequired {
+		return nil
+	}
+	return validation.Validate(value, validation.Required)
+}
+
+// requiredWithFees requires the account that receives the fees when a fee schedule is configured
+func (config *EnvConfig) requiredWithFees(value interface{}) error {
+	if config.FeeScheduleFile == "" {
+		return nil
+	}
+	return validation.Validate(value, validation.Required)
+}
+
+// requiredWithFileExporter requires the trace file when spans are exported to a file
+func (config *EnvConfig) requiredWithFileExporter(value interface{}) error {
+	if config.TracingExporter != "file" {
+		return nil
+	}
+	return validation.Validate(value, validation.Required)
+}
+
+// requiredWithoutKeyID requires the shared JWT secret unless tokens are signed with an asymmetric key
+func (config *EnvConfig) requiredWithoutKeyID(value interface{}) error {
+	if config.JWTKeyID != "" {
+		return nil
+	}
+	return validation.Validate(value, validation.Required)
+}
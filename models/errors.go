@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kinds of domain errors. Services wrap them in an *Error so handlers can map them to a status code with SendError.
+var (
+	ErrValidation            = errors.New("validation failed")
+	ErrNotFound              = errors.New("not found")
+	ErrConflict              = errors.New("conflict")
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrAccountFrozen         = errors.New("account frozen")
+	ErrAccountClosed         = errors.New("account closed")
+	ErrLimitExceeded         = errors.New("limit exceeded")
+	ErrRiskDenied            = errors.New("risk denied")
+	ErrLockTimeout           = errors.New("lock timeout")
+	ErrDependencyUnavailable = errors.New("dependency unavailable")
+)
+
+// Machine-readable error codes returned in the code field of error responses
+const (
+	ErrorCodeValidation            = "validation_error"
+	ErrorCodeUnauthorized          = "unauthorized"
+	ErrorCodeForbidden             = "forbidden"
+	ErrorCodeNotFound              = "not_found"
+	ErrorCodeMethodNotAllowed      = "method_not_allowed"
+	ErrorCodeConflict              = "conflict"
+	ErrorCodeInsufficientFunds     = "insufficient_funds"
+	ErrorCodeAccountFrozen         = "account_frozen"
+	ErrorCodeAccountClosed         = "account_closed"
+	ErrorCodeLimitExceeded         = "limit_exceeded"
+	ErrorCodeRiskDenied            = "risk_denied"
+	ErrorCodeLockTimeout           = "lock_timeout"
+	ErrorCodeDependencyUnavailable = "dependency_unavailable"
+	ErrorCodeInternal              = "internal_error"
+)
+
+// errorKinds maps every kind of domain error to its status code and error code
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{ErrValidation, http.StatusBadRequest, ErrorCodeValidation},
+	{ErrNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrConflict, http.StatusConflict, ErrorCodeConflict},
+	{ErrInsufficientFunds, http.StatusUnprocessableEntity, ErrorCodeInsufficientFunds},
+	{ErrAccountFrozen, http.StatusConflict, ErrorCodeAccountFrozen},
+	{ErrAccountClosed, http.StatusGone, ErrorCodeAccountClosed},
+	{ErrLimitExceeded, http.StatusUnprocessableEntity, ErrorCodeLimitExceeded},
+	{ErrRiskDenied, http.StatusUnprocessableEntity, ErrorCodeRiskDenied},
+	{ErrLockTimeout, http.StatusServiceUnavailable, ErrorCodeLockTimeout},
+	{ErrDependencyUnavailable, http.StatusServiceUnavailable, ErrorCodeDependencyUnavailable},
+}
+
+// Error is a domain error of a kind with a message that is safe to return to the caller.
+// The underlying cause is kept for logs and errors.Is, but never sent to the caller.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+// NewError returns a domain error of the kind
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// WrapError returns a domain error of the kind caused by err
+func WrapError(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrNotFound) and the like match errors of that kind
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// ErrorStatus returns the status code and error code of err and the message to return to the caller.
+// Errors that are not domain errors are reported as internal errors without exposing their message.
+func ErrorStatus(err error) (int, string, string) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		for _, kind := range errorKinds {
+			if errors.Is(domainErr.Kind, kind.kind) {
+				return kind.status, kind.code, domainErr.Message
+			}
+		}
+	}
+	return http.StatusInternalServerError, ErrorCodeInternal, "Internal Server Error"
+}
+
+// errorCodeForStatus returns the error code of responses sent with a status code instead of a domain error
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeValidation
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrorCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusServiceUnavailable:
+		return ErrorCodeDependencyUnavailable
+	default:
+		return ErrorCodeInternal
+	}
+}
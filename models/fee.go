@@ -0,0 +1,28 @@
+package models
+
+// Transaction kinds a fee schedule can be configured for. Credits are always free,
+// and so is every kind without a schedule.
+const (
+	FeeTypeDebit      = "debit"
+	FeeTypeMove       = "move"
+	FeeTypeConversion = "conversion"
+)
+
+// Fee is the breakdown of a fee charged on a transaction
+type Fee struct {
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	Flat          float64 `json:"flat"`
+	Percentage    float64 `json:"percentage"`
+	// Capped is "min" or "max" when the minimum or maximum fee of the schedule applied
+	Capped string `json:"capped,omitempty"`
+}
+
+// Posting is the outcome of a credit, move or conversion: the transaction that was written with the fee charged on it
+// and the conversion it made, or the hold when a risk rule held it for review
+type Posting struct {
+	TransactionID string
+	Fee           *Fee
+	Conversion    *FxConversion
+	Hold          *RiskHold
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// FxRate is the mid-market rate of a currency pair: how many units of Quote one unit of Base buys.
+// Conversions get the rate less the spread in percent. The inverse pair is derived from it when it is not set itself.
+type FxRate struct {
+	gorm.Model
+	Base      string  `json:"base" gorm:"type:varchar(3);unique_index:idx_fx_rates_pair;not null"`
+	Quote     string  `json:"quote" gorm:"type:varchar(3);unique_index:idx_fx_rates_pair;not null"`
+	Rate      float64 `json:"rate"`
+	Spread    float64 `json:"spread"`
+	Source    string  `json:"source"`
+	UpdatedBy string  `json:"updated_by"`
+}
+
+func (FxRate) TableName() string {
+	return "fx_rates"
+}
+
+// FxConversion is the price of converting an amount between two wallets in different currencies.
+// Quotes carry an ID and expire; a conversion made with a quote gets exactly its rate.
+type FxConversion struct {
+	QuoteID         string     `json:"quote_id,omitempty"`
+	TransactionID   string     `json:"transaction_id,omitempty"`
+	UID             string     `json:"uid"`
+	FromWallet      string     `json:"from_wallet"`
+	ToWallet        string     `json:"to_wallet"`
+	FromCurrency    string     `json:"from_currency"`
+	ToCurrency      string     `json:"to_currency"`
+	Amount          float64    `json:"amount"`
+	ConvertedAmount float64    `json:"converted_amount"`
+	Rate            float64    `json:"rate"`
+	Spread          float64    `json:"spread"`
+	Source          string     `json:"source"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
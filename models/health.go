@@ -0,0 +1,19 @@
+package models
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// DependencyHealth is the result of checking a single dependency
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness reports whether the instance can serve traffic and the health of each dependency
+type Readiness struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Names of the limits of an account
+const (
+	LimitOverdraft = "overdraft"
+)
+
+// Limits are the limits of an account and the funds they leave available in its main wallet
+type Limits struct {
+	UID            string  `json:"uid"`
+	OverdraftLimit float64 `json:"overdraft_limit"`
+	Balance        float64 `json:"balance"`
+	Available      float64 `json:"available"`
+}
+
+// LimitChange is the audit record of a change to a limit of a user account
+type LimitChange struct {
+	gorm.Model
+	UserID   uint    `json:"-" gorm:"index;not null"`
+	UID      string  `json:"uid" gorm:"index;not null"`
+	Limit    string  `json:"limit" gorm:"not null"`
+	OldValue float64 `json:"old_value"`
+	NewValue float64 `json:"new_value"`
+	Note     string  `json:"note"`
+	Actor    string  `json:"actor" gorm:"not null"`
+}
+
+func (LimitChange) TableName() string {
+	return "limit_changes"
+}
@@ -4,6 +4,7 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"regexp"
+	"time"
 )
 
 type AuthRequest struct {
@@ -18,7 +19,6 @@ func (a AuthRequest) Validate() error {
 
 type RefreshRequest struct {
 	Token string `json:"token"`
-	Email string `json:"email"`
 }
 
 func (a RefreshRequest) Validate() error {
@@ -32,5 +32,236 @@ func (a RefreshRequest) Validate() error {
 }
 
 type AddFundsRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   float64           `json:"amount"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type CreateUserRequest struct {
+	UID      string            `json:"uid"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func (a CreateUserRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(
+			&a.UID,
+			validation.Required,
+			validation.Length(1, 64),
+			validation.Match(regexp.MustCompile("^[A-Za-z0-9_-]+$")).Error("must contain only letters, digits, dashes and underscores"),
+		),
+		validation.Field(&a.Metadata, validation.Length(0, 32)),
+	)
+}
+
+type FreezeUserRequest struct {
+	ReasonCode   string `json:"reason_code"`
+	Note         string `json:"note"`
+	BlockCredits bool   `json:"block_credits"`
+}
+
+func (a FreezeUserRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ReasonCode, validation.Required, validation.In(ReasonCodes...)),
+		validation.Field(&a.Note, validation.Length(0, 1000)),
+	)
+}
+
+type UnfreezeUserRequest struct {
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note"`
+}
+
+func (a UnfreezeUserRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ReasonCode, validation.Required, validation.In(ReasonCodes...)),
+		validation.Field(&a.Note, validation.Length(0, 1000)),
+	)
+}
+
+type CloseUserRequest struct {
+	ReasonCode   string `json:"reason_code"`
+	Note         string `json:"note"`
+	SweepBalance bool   `json:"sweep_balance"`
+}
+
+func (a CloseUserRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ReasonCode, validation.Required, validation.In(ReasonCodes...)),
+		validation.Field(&a.Note, validation.Length(0, 1000)),
+	)
+}
+
+type CreateWalletRequest struct {
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+}
+
+func (a CreateWalletRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(
+			&a.Name,
+			validation.Required,
+			validation.Length(1, 32),
+			validation.Match(regexp.MustCompile("^[a-z0-9_-]+$")).Error("must contain only lowercase letters, digits, dashes and underscores"),
+		),
+		validation.Field(&a.Currency, validation.Match(regexp.MustCompile("^[A-Z]{3}$")).Error("must be a three-letter ISO 4217 code such as EUR")),
+	)
+}
+
+type MoveFundsRequest struct {
+	ToWallet string            `json:"to_wallet"`
+	Amount   float64           `json:"amount"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func (a MoveFundsRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ToWallet, validation.Required),
+		validation.Field(&a.Amount, validation.Required),
+	)
+}
+
+type CreateRecurringTransferRequest struct {
+	ToWallet string     `json:"to_wallet"`
+	Amount   float64    `json:"amount"`
+	Interval string     `json:"interval"`
+	StartAt  *time.Time `json:"start_at"`
+}
+
+func (a CreateRecurringTransferRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ToWallet, validation.Required),
+		validation.Field(&a.Amount, validation.Required),
+		validation.Field(&a.Interval, validation.Required, validation.In(Intervals...)),
+	)
+}
+
+type ConvertFundsRequest struct {
+	ToWallet string            `json:"to_wallet"`
+	Amount   float64           `json:"amount"`
+	QuoteID  string            `json:"quote_id"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func (a ConvertFundsRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ToWallet, validation.Required),
+		validation.Field(&a.Amount, validation.Required),
+	)
+}
+
+type FxRateRequest struct {
+	Base   string  `json:"base" yaml:"base"`
+	Quote  string  `json:"quote" yaml:"quote"`
+	Rate   float64 `json:"rate" yaml:"rate"`
+	Spread float64 `json:"spread" yaml:"spread"`
+	Source string  `json:"source" yaml:"source"`
+}
+
+func (a FxRateRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Base, validation.Required, validation.Match(regexp.MustCompile("^[A-Z]{3}$")).Error("must be a three-letter ISO 4217 code such as EUR")),
+		validation.Field(&a.Quote, validation.Required, validation.Match(regexp.MustCompile("^[A-Z]{3}$")).Error("must be a three-letter ISO 4217 code such as EUR"), validation.NotIn(a.Base).Error("must differ from base")),
+		validation.Field(&a.Rate, validation.Required, validation.Min(0.0).Exclusive()),
+		validation.Field(&a.Spread, validation.Min(0.0), validation.Max(100.0).Exclusive()),
+		validation.Field(&a.Source, validation.Length(0, 255)),
+	)
+}
+
+type SetFxRatesRequest struct {
+	Rates []FxRateRequest `json:"rates"`
+}
+
+func (a SetFxRatesRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Rates, validation.Required, validation.Length(1, 500)),
+	)
+}
+
+type BatchItemRequest struct {
+	IdempotencyKey string            `json:"idempotency_key"`
+	UID            string            `json:"uid"`
+	Wallet         string            `json:"wallet"`
+	Type           string            `json:"type"`
+	Amount         float64           `json:"amount"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+func (a BatchItemRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(
+			&a.IdempotencyKey,
+			validation.Required,
+			validation.Length(1, 128),
+			validation.Match(regexp.MustCompile("^\\S+$")).Error("cannot contain whitespaces"),
+		),
+		validation.Field(&a.UID, validation.Required),
+		validation.Field(&a.Type, validation.Required, validation.In(BatchItemTypes...)),
+		validation.Field(&a.Amount, validation.Required, validation.Min(0.0).Exclusive()),
+	)
+}
+
+type CreateBatchRequest struct {
+	Mode  string             `json:"mode"`
+	Items []BatchItemRequest `json:"items"`
+}
+
+func (a CreateBatchRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Mode, validation.Required, validation.In(BatchModes...)),
+		validation.Field(&a.Items, validation.Required),
+	)
+}
+
+type SetLimitsRequest struct {
+	OverdraftLimit *float64 `json:"overdraft_limit"`
+	Note           string   `json:"note"`
+}
+
+func (a SetLimitsRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.OverdraftLimit, validation.NotNil, validation.Min(0.0)),
+		validation.Field(&a.Note, validation.Required, validation.Length(0, 1000)),
+	)
+}
+
+type ReviewHoldRequest struct {
+	Note string `json:"note"`
+}
+
+func (a ReviewHoldRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Note, validation.Length(0, 1000)),
+	)
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name"`
+	Scopes      []string   `json:"scopes"`
+	AllowedUIDs []string   `json:"allowed_uids"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func (a CreateAPIKeyRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(
+			&a.Name,
+			validation.Required,
+			validation.Length(3, 64),
+			validation.Match(regexp.MustCompile("^[a-z0-9_-]+$")).Error("must contain only lowercase letters, digits, dashes and underscores"),
+		),
+		validation.Field(&a.Scopes, validation.Required, validation.Each(validation.In(Scopes...))),
+		validation.Field(&a.AllowedUIDs, validation.Each(validation.Required)),
+		validation.Field(&a.ExpiresAt, validation.Min(time.Now()).Error("must be in the future")),
+	)
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (a LogLevelRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Level, validation.Required, validation.In("debug", "info", "warn", "error")),
+	)
 }
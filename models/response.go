@@ -2,18 +2,51 @@ package models
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 	"net/http"
+	"strings"
 )
 
+// ProblemContentType is sent instead of the Response envelope to clients that accept RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
 // Response Base response
 type Response struct {
 	StatusCode int            `json:"-"`
 	Success    bool           `json:"success"`
+	Code       string         `json:"code,omitempty"`
 	Message    string         `json:"message,omitempty"`
 	Data       map[string]any `json:"data,omitempty"`
 }
 
+// Problem is an RFC 7807 problem details object with the error code as an extension member
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
 func (response *Response) SendResponse(c *gin.Context) {
+	if !response.Success && response.Code == "" {
+		response.Code = errorCodeForStatus(response.StatusCode)
+	}
+
+	if !response.Success && acceptsProblem(c) {
+		c.Render(response.StatusCode, problemRender{Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(response.StatusCode),
+			Status:   response.StatusCode,
+			Detail:   response.Message,
+			Instance: c.Request.URL.Path,
+			Code:     response.Code,
+		}})
+		c.Abort()
+		return
+	}
+
 	c.AbortWithStatusJSON(response.StatusCode, response)
 }
 
@@ -30,7 +63,41 @@ func SendErrorResponse(c *gin.Context, status int, message string) {
 	response := &Response{
 		StatusCode: status,
 		Success:    false,
+		Code:       errorCodeForStatus(status),
+		Message:    message,
+	}
+	response.SendResponse(c)
+}
+
+// SendError sends the status code, error code and message of a domain error, see ErrorStatus
+func SendError(c *gin.Context, err error) {
+	status, code, message := ErrorStatus(err)
+	_ = c.Error(err)
+
+	response := &Response{
+		StatusCode: status,
+		Success:    false,
+		Code:       code,
 		Message:    message,
 	}
 	response.SendResponse(c)
 }
+
+// acceptsProblem reports whether the client asked for RFC 7807 problem details
+func acceptsProblem(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), ProblemContentType)
+}
+
+// problemRender writes a Problem as JSON with the problem content type
+type problemRender struct {
+	problem Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return render.WriteJSON(w, r.problem)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
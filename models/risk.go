@@ -0,0 +1,66 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"time"
+)
+
+// Actions a risk rule can decide on
+const (
+	RiskActionAllow = "allow"
+	RiskActionDeny  = "deny"
+	RiskActionHold  = "hold"
+)
+
+// States of a held transaction
+const (
+	HoldStatusPending  = "pending"
+	HoldStatusApproved = "approved"
+	HoldStatusRejected = "rejected"
+)
+
+// HoldStatuses lists every state of a held transaction
+var HoldStatuses = []interface{}{HoldStatusPending, HoldStatusApproved, HoldStatusRejected}
+
+// RiskInput is what risk rules evaluate before a credit, debit or move between wallets is written
+type RiskInput struct {
+	User     User
+	Wallet   string
+	ToWallet string
+	Type     string
+	Amount   float64
+	Metadata map[string]string
+	// RecentActivity are the transactions of the user in the last day, newest first
+	RecentActivity []Transaction
+}
+
+// RiskDecision is the verdict of a rule on a transaction
+type RiskDecision struct {
+	Action string
+	Rule   string
+	Reason string
+}
+
+// RiskHold is a transaction held for review by a risk rule. It is written once an operator approves it.
+type RiskHold struct {
+	gorm.Model
+	TransactionID string         `json:"transaction_id" gorm:"unique;not null"`
+	UserID        uint           `json:"-" gorm:"index;not null"`
+	UID           string         `json:"uid" gorm:"index;not null"`
+	Wallet        string         `json:"wallet" gorm:"not null"`
+	ToWallet      string         `json:"to_wallet,omitempty"`
+	Type          string         `json:"type" gorm:"not null"`
+	Amount        float64        `json:"amount"`
+	Metadata      postgres.Jsonb `json:"metadata" swaggertype:"object"`
+	Rule          string         `json:"rule" gorm:"not null"`
+	Reason        string         `json:"reason"`
+	Status        string         `json:"status" gorm:"type:varchar(16);index;not null"`
+	ReviewedBy    string         `json:"reviewed_by,omitempty"`
+	ReviewNote    string         `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time     `json:"reviewed_at,omitempty"`
+}
+
+func (RiskHold) TableName() string {
+	return "risk_holds"
+}
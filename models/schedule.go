@@ -0,0 +1,64 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// Intervals of recurring transfers
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+)
+
+// Intervals lists every interval a recurring transfer can run at
+var Intervals = []interface{}{IntervalDaily, IntervalWeekly, IntervalMonthly}
+
+// InterestAccrual is the interest a wallet earned on its balance on one day. It is posted as an interest
+// transaction at the start of the next month, after which TransactionID names that transaction.
+type InterestAccrual struct {
+	gorm.Model
+	UserID        uint      `gorm:"index;not null"`
+	WalletID      uint      `gorm:"unique_index:idx_interest_accruals_wallet_day;not null"`
+	Day           time.Time `gorm:"type:date;unique_index:idx_interest_accruals_wallet_day;not null"`
+	Balance       float64
+	Rate          float64
+	Amount        float64
+	TransactionID string `gorm:"index;not null;default:''"`
+}
+
+func (InterestAccrual) TableName() string {
+	return "interest_accruals"
+}
+
+// RecurringTransfer moves an amount between two wallets of a user at a fixed interval, such as a weekly sweep to savings
+type RecurringTransfer struct {
+	gorm.Model
+	UserID     uint    `json:"-" gorm:"index;not null"`
+	UID        string  `json:"uid" gorm:"index;not null"`
+	FromWallet string  `json:"from_wallet" gorm:"not null"`
+	ToWallet   string  `json:"to_wallet" gorm:"not null"`
+	Amount     float64 `json:"amount"`
+	Interval   string  `json:"interval" gorm:"type:varchar(16);not null"`
+	// DayOfMonth is the day monthly transfers run on, the last day of the month in months without it
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	NextRunAt  time.Time  `json:"next_run_at" gorm:"index;not null"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+func (RecurringTransfer) TableName() string {
+	return "recurring_transfers"
+}
+
+// JobRun records that a scheduled job completed for a period, so it is not run again for it
+type JobRun struct {
+	gorm.Model
+	Job    string `gorm:"unique_index:idx_job_runs_job_period;not null"`
+	Period string `gorm:"unique_index:idx_job_runs_job_period;not null"`
+}
+
+func (JobRun) TableName() string {
+	return "job_runs"
+}
@@ -0,0 +1,72 @@
+package models
+
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeBalanceRead = "balance:read"
+	ScopeHistoryRead = "history:read"
+	ScopeFundsCredit = "funds:credit"
+	ScopeFundsDebit  = "funds:debit"
+	ScopeRiskReview  = "risk:review"
+	ScopeAdmin       = "admin"
+)
+
+// Scopes lists every scope that can be granted to a caller
+var Scopes = []interface{}{ScopeUsersRead, ScopeUsersWrite, ScopeBalanceRead, ScopeHistoryRead, ScopeFundsCredit, ScopeFundsDebit, ScopeRiskReview, ScopeAdmin}
+
+const (
+	RoleSupport  = "support"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists every role that can be assigned to a user
+var Roles = []interface{}{RoleSupport, RoleOperator, RoleAdmin}
+
+// RoleScopes are the scopes granted to users of each role
+var RoleScopes = map[string][]string{
+	RoleSupport:  {ScopeUsersRead, ScopeBalanceRead, ScopeHistoryRead},
+	RoleOperator: {ScopeUsersRead, ScopeUsersWrite, ScopeBalanceRead, ScopeHistoryRead, ScopeFundsCredit, ScopeFundsDebit, ScopeRiskReview},
+	RoleAdmin:    {ScopeAdmin},
+}
+
+const (
+	PrincipalTypeUser   = "user"
+	PrincipalTypeAPIKey = "api_key"
+)
+
+// Principal is the authenticated caller of a request, either a user with a JWT or a service with an API key
+type Principal struct {
+	Type        string
+	Name        string
+	Scopes      []string
+	AllowedUIDs []string
+}
+
+// Ref names the principal uniquely across principal types, such as api_key:payouts or user:ops@example.com
+func (p *Principal) Ref() string {
+	return p.Type + ":" + p.Name
+}
+
+// HasScope reports whether the principal was granted the scope. The admin scope grants every scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessUID reports whether the principal may act on the UID. An empty allow-list allows every UID.
+func (p *Principal) CanAccessUID(uid string) bool {
+	if len(p.AllowedUIDs) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedUIDs {
+		if allowed == uid {
+			return true
+		}
+	}
+	return false
+}
@@ -13,14 +13,18 @@ const (
 
 type UserClaims struct {
 	jwt.RegisteredClaims
-	Email string `json:"email"`
-	Type  string `json:"type"`
+	Email    string   `json:"email"`
+	Type     string   `json:"type"`
+	FamilyID string   `json:"fam,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
 }
 
 type Token struct {
 	ID          int64     `json:"id" gorm:"column:id;primary_key"`
 	Token       string    `json:"token" bson:"token"`
 	Type        string    `json:"type" bson:"type"`
+	FamilyID    string    `json:"family_id" bson:"family_id" gorm:"index"`
 	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
 	Blacklisted bool      `json:"blacklisted" bson:"blacklisted"`
 }
@@ -32,3 +36,19 @@ func (model Token) GetResponseJson() gin.H {
 func (Token) TableName() string {
 	return "tokens"
 }
+
+// JSONWebKey is the public part of a signing key as published in the JWKS document
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
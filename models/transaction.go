@@ -4,10 +4,50 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+// DefaultCurrency is the currency of main wallets and of wallets opened without a currency
+const DefaultCurrency = "USD"
+
+// Types of transactions. Credits and debits move money into or out of an account,
+// moves only shift it between wallets of the same account. Fees are charged to the paying wallet
+// and received by the fee account as fee income. Interest is credited monthly by the scheduler.
+// Conversions debit a wallet in one currency and credit a wallet of the same account in another.
+const (
+	TransactionTypeCredit    = "credit"
+	TransactionTypeDebit     = "debit"
+	TransactionTypeMoveIn    = "move_in"
+	TransactionTypeMoveOut   = "move_out"
+	TransactionTypeFee       = "fee"
+	TransactionTypeFeeIncome = "fee_income"
+	TransactionTypeInterest  = "interest"
+	TransactionTypeFxOut     = "fx_out"
+	TransactionTypeFxIn      = "fx_in"
+)
+
+// outgoingTypes are the transaction types that take money out of their wallet; every other type adds to it
+var outgoingTypes = map[string]bool{
+	TransactionTypeDebit:   true,
+	TransactionTypeMoveOut: true,
+	TransactionTypeFee:     true,
+	TransactionTypeFxOut:   true,
+}
+
+// IsOutgoing tells whether a transaction of the type takes money out of its wallet
+func IsOutgoing(transactionType string) bool {
+	return outgoingTypes[transactionType]
+}
+
 type Transaction struct {
 	gorm.Model
 	UserID        uint
+	WalletID      uint `gorm:"index"`
 	Amount        float64
-	Type          string // "credit" or "debit"
+	Type          string // one of the transaction types
 	TransactionID string `gorm:"unique;not null"`
+	// RelatedTransactionID links a fee to the transaction it was charged on
+	RelatedTransactionID string `gorm:"index"`
+	Currency             string `gorm:"type:varchar(3);not null;default:'USD'"`
+	// Both legs of a conversion record the rate applied, the spread in percent included in it and the source of the rate
+	FxRate   float64
+	FxSpread float64
+	FxSource string
 }
@@ -2,11 +2,26 @@ package models
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
 )
 
+// States of a user account
+const (
+	UserStatusActive = "active"
+	UserStatusFrozen = "frozen"
+	UserStatusClosed = "closed"
+)
+
+// User is an account identified by its UID. Its balances are kept in its wallets.
 type User struct {
 	gorm.Model
-	UID          string `gorm:"unique;not null"`
-	Balance      float64
-	Transactions []Transaction
+	UID    string `json:"uid" gorm:"unique;not null"`
+	Status string `json:"status" gorm:"type:varchar(16);not null;default:'active'"`
+	// CreditsBlocked is set when a frozen account may not receive credits either
+	CreditsBlocked bool `json:"credits_blocked"`
+	// OverdraftLimit is how far debits may take the balance of the main wallet below zero
+	OverdraftLimit float64        `json:"overdraft_limit" gorm:"not null;default:0"`
+	Metadata       postgres.Jsonb `json:"metadata" swaggertype:"object"`
+	Wallets        []Wallet       `json:"wallets,omitempty"`
+	Transactions   []Transaction  `json:"-"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// MainWallet is the wallet every user is opened with. Routes without a wallet act on it.
+const MainWallet = "main"
+
+// Wallet is a named sub-account of a user with its own balance and history in one currency
+type Wallet struct {
+	gorm.Model
+	UserID       uint          `json:"-" gorm:"unique_index:idx_wallets_user_name;not null"`
+	Name         string        `json:"name" gorm:"unique_index:idx_wallets_user_name;not null"`
+	Currency     string        `json:"currency" gorm:"type:varchar(3);not null;default:'USD'"`
+	Balance      float64       `json:"balance"`
+	Transactions []Transaction `json:"-"`
+}
+
+func (Wallet) TableName() string {
+	return "wallets"
+}
@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/controllers"
+	"ledger-service/middlewares"
+	"ledger-service/middlewares/validators"
+	"ledger-service/models"
+)
+
+func AdminRoute(router *gin.RouterGroup) {
+	admin := router.Group("/admin", middlewares.AuthMiddleware(), middlewares.RequireScope(models.ScopeAdmin))
+	{
+		admin.POST(
+			"/api_keys",
+			middlewares.SignatureMiddleware(),
+			validators.CreateAPIKeyValidator(),
+			controllers.CreateAPIKey,
+		)
+		admin.GET(
+			"/api_keys",
+			controllers.ListAPIKeys,
+		)
+		admin.DELETE(
+			"/api_keys/:name",
+			middlewares.SignatureMiddleware(),
+			controllers.RevokeAPIKey,
+		)
+		admin.PUT(
+			"/fx_rates",
+			middlewares.SignatureMiddleware(),
+			validators.SetFxRatesValidator(),
+			controllers.SetFxRates,
+		)
+		admin.POST(
+			"/users/:uid/freeze",
+			middlewares.SignatureMiddleware(),
+			validators.FreezeUserValidator(),
+			controllers.FreezeUser,
+		)
+		admin.POST(
+			"/users/:uid/unfreeze",
+			middlewares.SignatureMiddleware(),
+			validators.UnfreezeUserValidator(),
+			controllers.UnfreezeUser,
+		)
+		admin.POST(
+			"/users/:uid/close",
+			middlewares.SignatureMiddleware(),
+			validators.CloseUserValidator(),
+			controllers.CloseUser,
+		)
+		admin.GET(
+			"/users/:uid/status_changes",
+			controllers.ListUserStatusChanges,
+		)
+		admin.PUT(
+			"/users/:uid/limits",
+			middlewares.SignatureMiddleware(),
+			validators.SetLimitsValidator(),
+			controllers.SetLimits,
+		)
+		admin.GET(
+			"/users/:uid/limit_changes",
+			controllers.ListLimitChanges,
+		)
+		admin.GET(
+			"/log_level",
+			controllers.GetLogLevel,
+		)
+		admin.PUT(
+			"/log_level",
+			middlewares.SignatureMiddleware(),
+			validators.LogLevelValidator(),
+			controllers.SetLogLevel,
+		)
+	}
+}
@@ -3,14 +3,19 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"ledger-service/controllers"
+	"ledger-service/middlewares"
 	"ledger-service/middlewares/validators"
+	"ledger-service/models"
 )
 
 func AuthRoute(router *gin.RouterGroup) {
 	auth := router.Group("/auth")
 	{
+		// Tokens are issued for an email the caller vouches for, so only admins can request them
 		auth.POST(
 			"/generate_access_token",
+			middlewares.AuthMiddleware(),
+			middlewares.RequireScope(models.ScopeAdmin),
 			validators.AuthValidator(),
 			controllers.GenerateAccessToken,
 		)
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/controllers"
+	"ledger-service/middlewares"
+	"ledger-service/middlewares/validators"
+	"ledger-service/models"
+)
+
+func BatchRoute(router *gin.RouterGroup) {
+	batches := router.Group("/batches", middlewares.AuthMiddleware(), middlewares.RequireScope(models.ScopeFundsCredit))
+	{
+		batches.POST(
+			"",
+			middlewares.SignatureMiddleware(),
+			validators.CreateBatchValidator(),
+			controllers.CreateBatch,
+		)
+		batches.GET(
+			"/:id",
+			controllers.GetBatch,
+		)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/controllers"
+	"ledger-service/middlewares"
+	"ledger-service/models"
+)
+
+func FxRoute(router *gin.RouterGroup) {
+	fx := router.Group("/fx", middlewares.AuthMiddleware(), middlewares.RequireScope(models.ScopeBalanceRead))
+	{
+		fx.GET(
+			"/rates",
+			controllers.ListFxRates,
+		)
+	}
+}
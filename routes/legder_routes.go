@@ -3,24 +3,111 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"ledger-service/controllers"
+	"ledger-service/middlewares"
+	"ledger-service/middlewares/validators"
+	"ledger-service/models"
 )
 
 func Legder(router *gin.RouterGroup) {
-	auth := router.Group("/")
+	auth := router.Group("/", middlewares.AuthMiddleware())
 	{
+		auth.POST(
+			"users",
+			middlewares.SignatureMiddleware(),
+			middlewares.RequireScope(models.ScopeUsersWrite),
+			validators.CreateUserValidator(),
+			controllers.CreateUser,
+		)
+		auth.GET(
+			"users/:uid",
+			middlewares.RequireScope(models.ScopeUsersRead),
+			controllers.GetUser,
+		)
+		auth.GET(
+			"users/:uid/limits",
+			middlewares.RequireScope(models.ScopeUsersRead),
+			controllers.GetLimits,
+		)
 		auth.POST(
 			"users/:uid/add",
-			//middlewares.JWTMiddleware(),
+			middlewares.RequireScope(models.ScopeFundsCredit),
+			middlewares.SignatureMiddleware(),
 			controllers.AddFunds,
 		)
 		auth.GET(
 			"users/:uid/balance",
-			//middlewares.JWTMiddleware(),
+			middlewares.RequireScope(models.ScopeBalanceRead),
 			controllers.GetBalance,
 		)
 		auth.GET(
 			"users/:uid/history",
-			//middlewares.JWTMiddleware(),
+			middlewares.RequireScope(models.ScopeHistoryRead),
+			controllers.GetTransactionHistory,
+		)
+		auth.POST(
+			"users/:uid/wallets",
+			middlewares.SignatureMiddleware(),
+			middlewares.RequireScope(models.ScopeUsersWrite),
+			validators.CreateWalletValidator(),
+			controllers.CreateWallet,
+		)
+		auth.GET(
+			"users/:uid/wallets",
+			middlewares.RequireScope(models.ScopeUsersRead),
+			controllers.ListWallets,
+		)
+		auth.POST(
+			"users/:uid/wallets/:wallet/add",
+			middlewares.RequireScope(models.ScopeFundsCredit),
+			middlewares.SignatureMiddleware(),
+			controllers.AddFunds,
+		)
+		auth.POST(
+			"users/:uid/wallets/:wallet/move",
+			middlewares.RequireScope(models.ScopeFundsDebit),
+			middlewares.SignatureMiddleware(),
+			validators.MoveFundsValidator(),
+			controllers.MoveFunds,
+		)
+		auth.POST(
+			"users/:uid/wallets/:wallet/quotes",
+			middlewares.RequireScope(models.ScopeBalanceRead),
+			validators.ConvertFundsValidator(),
+			controllers.CreateFxQuote,
+		)
+		auth.POST(
+			"users/:uid/wallets/:wallet/convert",
+			middlewares.RequireScope(models.ScopeFundsDebit),
+			middlewares.SignatureMiddleware(),
+			validators.ConvertFundsValidator(),
+			controllers.ConvertFunds,
+		)
+		auth.POST(
+			"users/:uid/wallets/:wallet/recurring_transfers",
+			middlewares.RequireScope(models.ScopeFundsDebit),
+			middlewares.SignatureMiddleware(),
+			validators.CreateRecurringTransferValidator(),
+			controllers.CreateRecurringTransfer,
+		)
+		auth.GET(
+			"users/:uid/recurring_transfers",
+			middlewares.RequireScope(models.ScopeUsersRead),
+			controllers.ListRecurringTransfers,
+		)
+		auth.DELETE(
+			"users/:uid/recurring_transfers/:id",
+			middlewares.SignatureMiddleware(),
+			middlewares.RequireScope(models.ScopeFundsDebit),
+			controllers.DeleteRecurringTransfer,
+		)
+		auth.GET(
+			"users/:uid/wallets/:wallet/balance",
+			middlewares.RequireScope(models.ScopeBalanceRead),
+			controllers.GetBalance,
+		)
+		auth.GET(
+			"users/:uid/wallets/:wallet/history",
+			middlewares.RequireScope(models.ScopeHistoryRead),
 			controllers.GetTransactionHistory,
 		)
 	}
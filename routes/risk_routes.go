@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"ledger-service/controllers"
+	"ledger-service/middlewares"
+	"ledger-service/middlewares/validators"
+	"ledger-service/models"
+)
+
+func RiskRoute(router *gin.RouterGroup) {
+	risk := router.Group("/risk", middlewares.AuthMiddleware(), middlewares.RequireScope(models.ScopeRiskReview))
+	{
+		risk.GET(
+			"/holds",
+			controllers.ListHolds,
+		)
+		risk.POST(
+			"/holds/:id/approve",
+			middlewares.SignatureMiddleware(),
+			validators.ReviewHoldValidator(),
+			controllers.ApproveHold,
+		)
+		risk.POST(
+			"/holds/:id/reject",
+			middlewares.SignatureMiddleware(),
+			validators.ReviewHoldValidator(),
+			controllers.RejectHold,
+		)
+	}
+}
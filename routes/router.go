@@ -6,6 +6,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"ledger-service/controllers"
 	"ledger-service/docs"
 	"ledger-service/middlewares"
 	"ledger-service/models"
@@ -17,36 +18,54 @@ func New() *gin.Engine {
 	r := gin.New()
 	initRoute(r)
 
-	r.Use(gin.LoggerWithWriter(middlewares.LogWriter()))
+	r.Use(middlewares.TracingMiddleware())
+	r.Use(middlewares.RequestIDMiddleware())
+	r.Use(middlewares.AccessLogMiddleware())
 	r.Use(gin.CustomRecovery(middlewares.AppRecovery()))
 	r.Use(middlewares.CORSMiddleware())
-	r.Use(middlewares.ResponseTimeMiddleware())
+	r.Use(middlewares.MetricsMiddleware())
 	r.Use(middlewares.Pagination())
 
 	v1 := r.Group("/v1")
 	{
 		AuthRoute(v1)
 		Legder(v1)
+		AdminRoute(v1)
+		RiskRoute(v1)
+		FxRoute(v1)
+		BatchRoute(v1)
 
 	}
 
 	docs.SwaggerInfo.BasePath = v1.BasePath()
 
+	// Liveness and readiness probes for nginx and the orchestrator
+	r.GET("/healthz", controllers.Healthz)
+	r.GET("/readyz", controllers.Readyz)
+
+	// Publish the public token signing keys
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	// Add Prometheus endpoint for metrics collection
 	r.GET("/metrics", func(c *gin.Context) {
 		promhttp.Handler().ServeHTTP(c.Writer, c.Request)
 	})
 
-	// Add pprof endpoint for profiling
-	pprof.Register(r)
+	// Add pprof endpoint for profiling, restricted to admins
+	debug := r.Group("/", middlewares.AuthMiddleware(), middlewares.RequireScope(models.ScopeAdmin))
+	pprof.RouteRegister(debug, "debug/pprof")
 
 	return r
 }
 
 func initRoute(r *gin.Engine) {
-	_ = r.SetTrustedProxies(nil)
+	if err := r.SetTrustedProxies(services.Config.TrustedProxies); err != nil {
+		panic(err)
+	}
 	r.RedirectTrailingSlash = false
+	// Let *gin.Context carry the request context, including the trace span, into the services
+	r.ContextWithFallback = true
 	r.HandleMethodNotAllowed = true
 
 	r.NoRoute(func(c *gin.Context) {
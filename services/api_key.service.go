@@ -0,0 +1,100 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"ledger-service/models"
+	"time"
+)
+
+// APIKeyPrefix marks API keys so they are easy to recognise in configs and leaked logs
+const APIKeyPrefix = "lk_"
+
+// BootstrapAPIKeyName is the name under which the ADMIN_API_KEY from the config is reported
+const BootstrapAPIKeyName = "bootstrap"
+
+// CreateAPIKey creates a new API key and returns it together with the plaintext key.
+// The plaintext key is never stored and cannot be retrieved again.
+func CreateAPIKey(request models.CreateAPIKeyRequest) (models.APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", errors.New("cannot generate api key")
+	}
+	key := APIKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey := models.APIKey{
+		Name:        request.Name,
+		Prefix:      key[:len(APIKeyPrefix)+8],
+		KeyHash:     hashAPIKey(key),
+		Scopes:      request.Scopes,
+		AllowedUIDs: request.AllowedUIDs,
+		ExpiresAt:   request.ExpiresAt,
+	}
+
+	var count int
+	if err := DbConnection.Model(&models.APIKey{}).Where("name = ?", request.Name).Count(&count).Error; err != nil {
+		return models.APIKey{}, "", models.WrapError(models.ErrDependencyUnavailable, "cannot save api key to db", err)
+	}
+	if count > 0 {
+		return models.APIKey{}, "", models.NewError(models.ErrConflict, "api key with this name already exists")
+	}
+
+	if err := DbConnection.Create(&apiKey).Error; err != nil {
+		return models.APIKey{}, "", models.WrapError(models.ErrDependencyUnavailable, "cannot save api key to db", err)
+	}
+
+	return apiKey, key, nil
+}
+
+// ListAPIKeys returns every API key, including revoked and expired ones
+func ListAPIKeys() ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	if err := DbConnection.Order("name").Find(&apiKeys).Error; err != nil {
+		return nil, models.WrapError(models.ErrDependencyUnavailable, "cannot fetch api keys from db", err)
+	}
+	return apiKeys, nil
+}
+
+// RevokeAPIKey revokes the API key with the given name
+func RevokeAPIKey(name string) error {
+	apiKey := &models.APIKey{}
+	if err := DbConnection.Where("name = ?", name).First(apiKey).Error; err != nil {
+		return models.NewError(models.ErrNotFound, "api key not found")
+	}
+
+	now := time.Now()
+	return DbConnection.Model(apiKey).Update("revoked_at", &now).Error
+}
+
+// VerifyAPIKey checks that the key exists, is not revoked and has not expired.
+// The bootstrap admin key from the config is accepted without a database record.
+func VerifyAPIKey(key string) (*models.APIKey, error) {
+	if Config.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(Config.AdminAPIKey)) == 1 {
+		return &models.APIKey{Name: BootstrapAPIKeyName, Scopes: []string{models.ScopeAdmin}}, nil
+	}
+
+	apiKey := &models.APIKey{}
+	if err := DbConnection.Where("key_hash = ?", hashAPIKey(key)).First(apiKey).Error; err != nil {
+		return nil, errors.New("not valid api key")
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil {
+		return nil, errors.New("api key is revoked")
+	}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, errors.New("api key is expired")
+	}
+
+	DbConnection.Model(apiKey).UpdateColumn("last_used_at", &now)
+	return apiKey, nil
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash under which a key is stored
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,792 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/metrics"
+	"ledger-service/models"
+	"sort"
+	"time"
+)
+
+// batchLockTTL is how long the locks of the wallets of a batch are held without being extended.
+// The locks are extended while the items are posted once less than half of it is left.
+const batchLockTTL = time.Minute
+
+// batchTransactionID returns the transaction ID an item with the idempotency key is posted as.
+// Keys are scoped by the caller, so callers that pick the same key do not collide.
+func batchTransactionID(caller string, idempotencyKey string) string {
+	return "batch:" + caller + ":" + idempotencyKey
+}
+
+// CreateBatch posts the credits and debits of a batch and records the result of every item.
+// Each wallet is locked once for the whole batch and its cache is invalidated once after it was written.
+// The caller scopes the idempotency keys of the items, the actor is recorded as the creator of the batch.
+func CreateBatch(ctx context.Context, request models.CreateBatchRequest, caller string, actor string) (models.Batch, error) {
+	if len(request.Items) > Config.BatchMaxItems {
+		return models.Batch{}, models.NewError(models.ErrValidation, fmt.Sprintf("A batch can have at most %d items", Config.BatchMaxItems))
+	}
+
+	keys := map[string]bool{}
+	items := make([]models.BatchItem, len(request.Items))
+	for i, item := range request.Items {
+		if keys[item.IdempotencyKey] {
+			return models.Batch{}, models.NewError(models.ErrValidation, "Idempotency key "+item.IdempotencyKey+" is used by more than one item")
+		}
+		keys[item.IdempotencyKey] = true
+
+		wallet := item.Wallet
+		if wallet == "" {
+			wallet = models.MainWallet
+		}
+		items[i] = models.BatchItem{
+			Position:       i + 1,
+			IdempotencyKey: item.IdempotencyKey,
+			UID:            item.UID,
+			Wallet:         wallet,
+			Type:           item.Type,
+			Amount:         item.Amount,
+			Status:         models.BatchItemStatusPending,
+			TransactionID:  batchTransactionID(caller, item.IdempotencyKey),
+			Metadata:       item.Metadata,
+		}
+	}
+
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.Batch{}, err
+	}
+	defer done()
+
+	// The batch is stored first, so it can be looked up while its items are posted
+	batch := models.Batch{
+		Mode:      request.Mode,
+		Status:    models.BatchStatusProcessing,
+		CreatedBy: actor,
+		ItemCount: len(items),
+	}
+	if err := dbWithContext(ctx).Create(&batch).Error; err != nil {
+		return models.Batch{}, classifyDBError(err, "Batch not found")
+	}
+
+	logCtx := logger.WithFields(ctx, zap.Uint("batch_id", batch.ID), zap.String("mode", batch.Mode))
+	if _, err := postBatchItems(ctx, logCtx, &batch, items, false); err != nil {
+		return models.Batch{}, err
+	}
+
+	logger.InfoCtx(logCtx, "Batch processed",
+		zap.String("status", batch.Status),
+		zap.Int("posted", batch.Posted),
+		zap.Int("held", batch.Held),
+		zap.Int("duplicate", batch.Duplicate),
+		zap.Int("failed", batch.Failed),
+	)
+	batch.Items = items
+	return batch, nil
+}
+
+// GetBatch returns a batch with the results of its items
+func GetBatch(ctx context.Context, id uint) (models.Batch, error) {
+	var batch models.Batch
+	err := dbWithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		First(&batch, id).Error
+	if err != nil {
+		return models.Batch{}, classifyDBError(err, "Batch not found")
+	}
+	return batch, nil
+}
+
+// postHeldDebit writes a debit of a batch that was held for review and approved, without evaluating the risk rules again
+func postHeldDebit(ctx context.Context, hold models.RiskHold, metadata map[string]string) error {
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	items := []models.BatchItem{{
+		Position:      1,
+		UID:           hold.UID,
+		Wallet:        hold.Wallet,
+		Type:          models.TransactionTypeDebit,
+		Amount:        hold.Amount,
+		Status:        models.BatchItemStatusPending,
+		TransactionID: hold.TransactionID,
+		Metadata:      metadata,
+	}}
+	logCtx := logger.WithFields(ctx, zap.String("transaction_id", hold.TransactionID))
+	itemErrors, err := postBatchItems(ctx, logCtx, &models.Batch{Mode: models.BatchModeAtomic}, items, true)
+	if err != nil {
+		return err
+	}
+	if items[0].Status == models.BatchItemStatusDuplicate {
+		return models.NewError(models.ErrConflict, "Transaction already processed")
+	}
+	return itemErrors[0]
+}
+
+// walletRef names a wallet of an account
+type walletRef struct {
+	uid  string
+	name string
+}
+
+// batchPosting is the state of a batch while its items are posted: the accounts and wallets it writes
+// by UID and wallet key, the locks it holds and what has to be undone or announced once it is done
+type batchPosting struct {
+	ctx      context.Context
+	logCtx   context.Context
+	db       *gorm.DB
+	atomic   bool
+	approved bool
+	items    []models.BatchItem
+	errors   []error
+
+	users      map[string]*models.User
+	owners     map[uint]string
+	wallets    map[string]*models.Wallet
+	feeWallets map[string]*models.Wallet
+	feeErrors  map[string]error
+	mutexes    []*redsync.Mutex
+	locked     map[string]bool
+	releases   []func()
+	failures   int
+	fees       float64
+	written    map[walletRef]bool
+}
+
+// postBatchItems posts the pending items of a batch under one lock per wallet and in one database transaction,
+// and returns why each item that failed was not posted. An atomic batch stops at the first item that fails and
+// posts nothing; a best-effort batch posts every other item. Stored batches are updated with the results of their
+// items in the same database transaction; batches that are not stored, like an approved hold, only post.
+func postBatchItems(ctx context.Context, logCtx context.Context, batch *models.Batch, items []models.BatchItem, approved bool) ([]error, error) {
+	p := &batchPosting{
+		ctx:        ctx,
+		logCtx:     logCtx,
+		db:         dbWithContext(ctx),
+		atomic:     batch.Mode == models.BatchModeAtomic,
+		approved:   approved,
+		items:      items,
+		errors:     make([]error, len(items)),
+		users:      map[string]*models.User{},
+		owners:     map[uint]string{},
+		wallets:    map[string]*models.Wallet{},
+		feeWallets: map[string]*models.Wallet{},
+		feeErrors:  map[string]error{},
+		locked:     map[string]bool{},
+		written:    map[walletRef]bool{},
+	}
+	defer func() { releaseLocks(p.mutexes) }()
+
+	// When the accounts cannot be loaded or locked, every pending item fails with the reason
+	err := p.load()
+	if err == nil {
+		err = p.markDuplicates()
+	}
+	if err == nil {
+		err = p.lock()
+	}
+	if err == nil {
+		// Checked again under the locks for items another request posted to the same wallets meanwhile
+		err = p.markDuplicates()
+	}
+	if err != nil {
+		for i := range items {
+			if items[i].Status == models.BatchItemStatusPending {
+				p.fail(i, err)
+			}
+		}
+	}
+	p.lockFeeWallets()
+
+	tx := p.db.Begin()
+	if tx.Error != nil {
+		return nil, classifyDBError(tx.Error, "Batch not found")
+	}
+	var lockErr error
+	for i := range items {
+		if items[i].Status != models.BatchItemStatusPending {
+			continue
+		}
+		if p.atomic && p.failed() {
+			items[i].Status = models.BatchItemStatusSkipped
+			continue
+		}
+		if lockErr = p.extendLocks(); lockErr != nil {
+			break
+		}
+		p.post(tx, i)
+	}
+	if lockErr == nil {
+		lockErr = p.extendLocks()
+	}
+
+	if lockErr != nil {
+		// Another request may have written the wallets since a lock was lost, so nothing of the batch is written, holds included
+		tx.Rollback()
+		p.releaseVelocity()
+		for i := range items {
+			if items[i].Status == models.BatchItemStatusPending || items[i].Status == models.BatchItemStatusPosted || items[i].Status == models.BatchItemStatusHeld {
+				items[i].Fee = 0
+				p.fail(i, lockErr)
+			}
+		}
+		tx = p.db.Begin()
+	} else if p.atomic && p.failed() {
+		// Nothing of an atomic batch is written when one of its items fails
+		tx.Rollback()
+		p.releaseVelocity()
+		for i := range items {
+			if items[i].Status == models.BatchItemStatusPosted {
+				items[i].Status = models.BatchItemStatusSkipped
+				items[i].Fee = 0
+			}
+		}
+		tx = p.db.Begin()
+	}
+
+	err = tx.Error
+	if err == nil && batch.ID != 0 {
+		err = saveBatch(tx, batch, items)
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		p.releaseVelocity()
+		logger.ErrorCtx(logCtx, "Error posting batch", zap.Error(err))
+		return nil, models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", err)
+	}
+
+	p.announce()
+	return p.errors, nil
+}
+
+// load finds the accounts and wallets of the items. Items of unknown accounts or wallets fail.
+func (p *batchPosting) load() error {
+	uids := map[string]bool{}
+	for _, item := range p.items {
+		uids[item.UID] = true
+	}
+
+	var users []models.User
+	if err := p.db.Where("uid IN (?)", setKeys(uids)).Find(&users).Error; err != nil {
+		return classifyDBError(err, "Users not found")
+	}
+	for i := range users {
+		p.users[users[i].UID] = &users[i]
+		p.owners[users[i].ID] = users[i].UID
+	}
+
+	if Config.AutoCreateUsers {
+		// Legacy behaviour: credits create the users they are made to, like single credits
+		for _, item := range p.items {
+			if p.users[item.UID] != nil || item.Type != models.TransactionTypeCredit {
+				continue
+			}
+			user := models.User{}
+			result := p.db.FirstOrCreate(&user, models.User{UID: item.UID, Status: models.UserStatusActive})
+			if result.Error == nil {
+				result = p.db.FirstOrCreate(&models.Wallet{}, models.Wallet{UserID: user.ID, Name: models.MainWallet, Currency: models.DefaultCurrency})
+			}
+			if result.Error != nil {
+				logger.ErrorCtx(p.logCtx, "Error creating user", zap.Error(result.Error))
+				return classifyDBError(result.Error, "User not found")
+			}
+			p.users[user.UID] = &user
+			p.owners[user.ID] = user.UID
+		}
+	}
+
+	userIDs := make([]uint, 0, len(p.users))
+	for _, user := range p.users {
+		userIDs = append(userIDs, user.ID)
+	}
+	var wallets []models.Wallet
+	if len(userIDs) > 0 {
+		if err := p.db.Where("user_id IN (?)", userIDs).Find(&wallets).Error; err != nil {
+			return classifyDBError(err, "Wallets not found")
+		}
+	}
+	for i := range wallets {
+		p.wallets[walletKey(p.owners[wallets[i].UserID], wallets[i].Name)] = &wallets[i]
+	}
+
+	for i, item := range p.items {
+		switch {
+		case p.users[item.UID] == nil:
+			p.fail(i, models.NewError(models.ErrNotFound, "User not found"))
+		case p.wallets[walletKey(item.UID, item.Wallet)] == nil:
+			p.fail(i, models.NewError(models.ErrNotFound, "Wallet not found"))
+		}
+	}
+	return nil
+}
+
+// markDuplicates reports the items whose transaction was already posted or held by an earlier request.
+// Items whose idempotency key was used for another account, wallet, type or amount fail with a conflict instead.
+func (p *batchPosting) markDuplicates() error {
+	pending := map[string]int{}
+	for i, item := range p.items {
+		if item.Status == models.BatchItemStatusPending {
+			pending[item.TransactionID] = i
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	transactionIDs := make([]string, 0, len(pending))
+	for transactionID := range pending {
+		transactionIDs = append(transactionIDs, transactionID)
+	}
+
+	// An approved hold is only a duplicate once its transaction was posted
+	var posted []models.Transaction
+	var held []models.RiskHold
+	err := p.db.Where("transaction_id IN (?)", transactionIDs).Find(&posted).Error
+	if err == nil && !p.approved {
+		err = p.db.Where("transaction_id IN (?)", transactionIDs).Find(&held).Error
+	}
+	if err != nil {
+		logger.ErrorCtx(p.logCtx, "Error checking for existing transactions", zap.Error(err))
+		return models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", err)
+	}
+
+	for _, transaction := range posted {
+		i := pending[transaction.TransactionID]
+		wallet := p.wallets[walletKey(p.items[i].UID, p.items[i].Wallet)]
+		p.markExisting(i, transaction.WalletID == wallet.ID && transaction.Type == p.items[i].Type && transaction.Amount == p.items[i].Amount)
+	}
+	for _, hold := range held {
+		i := pending[hold.TransactionID]
+		p.markExisting(i, hold.UID == p.items[i].UID && hold.Wallet == p.items[i].Wallet && hold.Type == p.items[i].Type && hold.Amount == p.items[i].Amount)
+	}
+	return nil
+}
+
+// markExisting reports a pending item whose transaction already exists as a duplicate when it was made for the same
+// item, and fails it when its idempotency key was used for another one
+func (p *batchPosting) markExisting(i int, same bool) {
+	switch {
+	case p.items[i].Status != models.BatchItemStatusPending:
+	case same:
+		p.items[i].Status = models.BatchItemStatusDuplicate
+	default:
+		p.fail(i, models.NewError(models.ErrConflict, "Idempotency key was already used for another item"))
+	}
+}
+
+// markConcurrentDuplicate reports an item whose transaction or hold another request wrote since the duplicates were
+// checked, which the unique index on its transaction ID rejected
+func (p *batchPosting) markConcurrentDuplicate(i int) {
+	if err := p.markDuplicates(); err != nil {
+		p.fail(i, err)
+		return
+	}
+	if p.items[i].Status == models.BatchItemStatusPending {
+		p.fail(i, models.NewError(models.ErrConflict, "Transaction already processed"))
+	}
+}
+
+// lock acquires the lock of every wallet with a pending item in wallet key order and reloads the wallets under them.
+// In a best-effort batch the items of a wallet that stays locked by another request fail, the others go ahead.
+func (p *batchPosting) lock() error {
+	pending := map[string]walletRef{}
+	for _, item := range p.items {
+		if item.Status == models.BatchItemStatusPending {
+			pending[walletKey(item.UID, item.Wallet)] = walletRef{uid: item.UID, name: item.Wallet}
+		}
+	}
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		mutex, err := lockWallet(p.ctx, p.logCtx, pending[key].uid, pending[key].name, redsync.WithExpiry(batchLockTTL))
+		if err == nil {
+			p.mutexes = append(p.mutexes, mutex)
+			p.locked[key] = true
+			continue
+		}
+		if p.atomic || !errors.Is(err, models.ErrLockTimeout) {
+			return err
+		}
+		for i, item := range p.items {
+			if item.Status == models.BatchItemStatusPending && walletKey(item.UID, item.Wallet) == key {
+				p.fail(i, err)
+			}
+		}
+	}
+
+	// Reload the accounts and wallets so their state and balances cannot change underneath the locks
+	return p.reload()
+}
+
+// extendLocks extends the locks of the batch that have less than half of batchLockTTL left,
+// so a large batch keeps its wallets locked until it is committed
+func (p *batchPosting) extendLocks() error {
+	for _, mutex := range p.mutexes {
+		if time.Until(mutex.Until()) > batchLockTTL/2 {
+			continue
+		}
+		extended, err := mutex.ExtendContext(p.ctx)
+		if err == nil && !extended {
+			err = redsync.ErrExtendFailed
+		}
+		if err != nil {
+			metrics.LockFailures.WithLabelValues("balance").Inc()
+			logger.ErrorCtx(p.logCtx, "Error extending lock", zap.String("lock", mutex.Name()), zap.Error(err))
+			return models.WrapError(models.ErrLockTimeout, "Batch took too long and lost the lock of a wallet, try again", err)
+		}
+	}
+	return nil
+}
+
+// reload reads the locked wallets and their accounts again
+func (p *batchPosting) reload() error {
+	var walletIDs, userIDs []uint
+	for key := range p.locked {
+		walletIDs = append(walletIDs, p.wallets[key].ID)
+		userIDs = append(userIDs, p.wallets[key].UserID)
+	}
+	if len(walletIDs) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	var wallets []models.Wallet
+	err := p.db.Where("id IN (?)", userIDs).Find(&users).Error
+	if err == nil {
+		err = p.db.Where("id IN (?)", walletIDs).Find(&wallets).Error
+	}
+	if err != nil {
+		logger.ErrorCtx(p.logCtx, "Error reloading wallets", zap.Error(err))
+		return classifyDBError(err, "Wallet not found")
+	}
+
+	for i := range users {
+		*p.users[users[i].UID] = users[i]
+	}
+	for i := range wallets {
+		*p.wallets[walletKey(p.owners[wallets[i].UserID], wallets[i].Name)] = wallets[i]
+	}
+	return nil
+}
+
+// lockFeeWallets locks the wallets of the fee account that receive the fees on the pending debits, after every
+// paying wallet. Debits in a currency whose fee wallet cannot be locked fail when posted.
+func (p *batchPosting) lockFeeWallets() {
+	currencies := map[string]bool{}
+	for _, item := range p.items {
+		if item.Status == models.BatchItemStatusPending && item.Type == models.TransactionTypeDebit && chargesFee(item.UID) {
+			currencies[p.wallets[walletKey(item.UID, item.Wallet)].Currency] = true
+		}
+	}
+	if _, ok := feeSchedules[models.FeeTypeDebit]; !ok || len(currencies) == 0 {
+		return
+	}
+
+	var account models.User
+	if err := p.db.Where("uid = ?", Config.FeeAccountUID).First(&account).Error; err != nil {
+		err = classifyDBError(err, "Fee account not found")
+		for currency := range currencies {
+			p.feeErrors[currency] = err
+		}
+		return
+	}
+
+	for _, currency := range setKeys(currencies) {
+		wallet, err := p.lockFeeWallet(account, currency)
+		if err != nil {
+			p.feeErrors[currency] = err
+			continue
+		}
+		p.feeWallets[currency] = wallet
+	}
+}
+
+// lockFeeWallet locks the wallet of the fee account in the currency, or takes it from the batch when one of its items
+// already locked it
+func (p *batchPosting) lockFeeWallet(account models.User, currency string) (*models.Wallet, error) {
+	wallet, err := findCurrencyWallet(p.db, account, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	key := walletKey(account.UID, wallet.Name)
+	if p.locked[key] {
+		if err := checkFeeAccount(*p.users[account.UID]); err != nil {
+			return nil, err
+		}
+		return p.wallets[key], nil
+	}
+
+	mutex, err := lockWallet(p.ctx, p.logCtx, account.UID, wallet.Name, redsync.WithExpiry(batchLockTTL))
+	if err != nil {
+		return nil, err
+	}
+	p.mutexes = append(p.mutexes, mutex)
+	if err := reloadLocked(p.db, &account, &wallet); err != nil {
+		return nil, classifyDBError(err, "Fee account not found")
+	}
+	if err := checkFeeAccount(account); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// post checks a pending item like a single credit or debit and writes it in the batch's database transaction.
+// The balances are kept in memory, so later items of the same wallet see the items before them.
+func (p *batchPosting) post(tx *gorm.DB, i int) {
+	item := &p.items[i]
+	user := p.users[item.UID]
+	wallet := p.wallets[walletKey(item.UID, item.Wallet)]
+
+	err := checkCredit(*user)
+	if item.Type == models.TransactionTypeDebit {
+		err = checkDebit(*user)
+	}
+	if err != nil {
+		p.fail(i, err)
+		return
+	}
+
+	if !p.approved {
+		input := models.RiskInput{User: *user, Wallet: item.Wallet, Type: item.Type, Amount: item.Amount, Metadata: item.Metadata}
+		// Read through the batch's transaction so the rules see the items of the batch posted before this one
+		decision, err := evaluateRisk(tx, p.logCtx, input)
+		if err == nil && decision != nil && decision.Action == models.RiskActionHold && p.atomic {
+			err = models.NewError(models.ErrRiskDenied, "Transaction would be held for review: "+decision.Reason)
+		}
+		if err == nil && decision != nil {
+			err = p.inSavepoint(tx, func() error {
+				_, err := applyRiskDecision(tx, input, decision, item.TransactionID)
+				return err
+			})
+			if err == nil {
+				item.Status = models.BatchItemStatusHeld
+				return
+			}
+			if isUniqueViolation(err, models.RiskHold{}.TableName()) {
+				p.markConcurrentDuplicate(i)
+				return
+			}
+		}
+		if err != nil {
+			p.fail(i, err)
+			return
+		}
+	}
+
+	// The fee of a debit is taken from the debited wallet on top of the amount
+	var fee *models.Fee
+	var feeWallet *models.Wallet
+	required := item.Amount
+	if item.Type == models.TransactionTypeDebit && chargesFee(item.UID) {
+		fee = calculateFee(models.FeeTypeDebit, item.Amount)
+	}
+	if fee != nil {
+		required += fee.Amount
+	}
+	switch {
+	case fee != nil && p.feeErrors[wallet.Currency] != nil:
+		err = p.feeErrors[wallet.Currency]
+	case item.Type == models.TransactionTypeDebit && availableFunds(*user, *wallet) < required:
+		err = models.NewError(models.ErrInsufficientFunds, "Insufficient funds")
+	}
+	if err != nil {
+		p.fail(i, err)
+		return
+	}
+	if fee != nil {
+		feeWallet = p.feeWallets[wallet.Currency]
+	}
+
+	release, err := reserveVelocity(p.ctx, p.logCtx, *user, item.Type, item.Amount)
+	if err != nil {
+		p.fail(i, err)
+		return
+	}
+
+	if err := p.write(tx, item, wallet, feeWallet, fee); err != nil {
+		release()
+		// Another request posted the transaction to another wallet since the duplicates were checked
+		if isUniqueViolation(err, "transactions") {
+			p.markConcurrentDuplicate(i)
+			return
+		}
+		logger.ErrorCtx(p.logCtx, "Error posting batch item", zap.String("transaction_id", item.TransactionID), zap.Error(err))
+		p.fail(i, models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", err))
+		return
+	}
+
+	p.releases = append(p.releases, release)
+	p.written[walletRef{uid: item.UID, name: item.Wallet}] = true
+	item.Status = models.BatchItemStatusPosted
+	if fee != nil {
+		item.Fee = fee.Amount
+		p.fees += fee.Amount
+		p.written[walletRef{uid: Config.FeeAccountUID, name: feeWallet.Name}] = true
+	}
+}
+
+// write records the transaction of an item and its fee. Every item is written in a savepoint, so one that fails
+// is undone without the items before it and an item that turns out to be a duplicate does not fail an atomic batch.
+func (p *batchPosting) write(tx *gorm.DB, item *models.BatchItem, wallet *models.Wallet, feeWallet *models.Wallet, fee *models.Fee) error {
+	balance := wallet.Balance
+	var feeBalance float64
+	if feeWallet != nil {
+		feeBalance = feeWallet.Balance
+	}
+
+	err := p.inSavepoint(tx, func() error {
+		amount := item.Amount
+		if item.Type == models.TransactionTypeDebit {
+			amount = -amount
+		}
+		err := tx.Create(&models.Transaction{
+			UserID:        wallet.UserID,
+			WalletID:      wallet.ID,
+			Amount:        item.Amount,
+			Type:          item.Type,
+			TransactionID: item.TransactionID,
+			Currency:      wallet.Currency,
+		}).Error
+		if err == nil {
+			wallet.Balance += amount
+			err = tx.Model(wallet).Update("balance", wallet.Balance).Error
+		}
+		if err == nil && fee != nil {
+			err = postFee(tx, wallet, feeWallet, fee, item.TransactionID)
+		}
+		return err
+	})
+	if err != nil {
+		wallet.Balance = balance
+		if feeWallet != nil {
+			feeWallet.Balance = feeBalance
+		}
+	}
+	return err
+}
+
+// inSavepoint runs write in a savepoint of the batch's transaction, so a failed item is undone without aborting the others
+func (p *batchPosting) inSavepoint(tx *gorm.DB, write func() error) error {
+	if err := tx.Exec("SAVEPOINT batch_item").Error; err != nil {
+		return err
+	}
+	err := write()
+	if err == nil {
+		return tx.Exec("RELEASE SAVEPOINT batch_item").Error
+	}
+	if rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_item").Error; rollbackErr != nil {
+		logger.ErrorCtx(p.logCtx, "Error rolling back batch item", zap.Error(rollbackErr))
+	}
+	return err
+}
+
+// announce invalidates the caches of the written wallets and records the postings once they are committed
+func (p *batchPosting) announce() {
+	for wallet := range p.written {
+		invalidateBalanceCache(p.ctx, p.logCtx, wallet.uid, wallet.name)
+	}
+
+	for _, item := range p.items {
+		if item.Status == models.BatchItemStatusPosted && item.Type == models.TransactionTypeCredit {
+			currency := p.wallets[walletKey(item.UID, item.Wallet)].Currency
+			metrics.FundsAdded.WithLabelValues(currency).Inc()
+			metrics.FundsAddedAmount.WithLabelValues(currency).Add(item.Amount)
+		}
+		metrics.BatchItems.WithLabelValues(item.Type, item.Status).Inc()
+	}
+	if p.fees > 0 {
+		metrics.FeesCharged.WithLabelValues(models.FeeTypeDebit).Add(p.fees)
+	}
+}
+
+// fail records why an item was not posted
+func (p *batchPosting) fail(i int, err error) {
+	_, code, message := models.ErrorStatus(err)
+	p.items[i].Status = models.BatchItemStatusFailed
+	p.items[i].ErrorCode = code
+	p.items[i].Error = message
+	p.errors[i] = err
+	p.failures++
+}
+
+// failed tells whether an item of the batch failed
+func (p *batchPosting) failed() bool {
+	return p.failures > 0
+}
+
+// releaseVelocity gives back the velocity reservations of the items that are not written after all
+func (p *batchPosting) releaseVelocity() {
+	for _, release := range p.releases {
+		release()
+	}
+	p.releases = nil
+}
+
+// saveBatch stores the results of the items and the counts and status of the batch
+func saveBatch(tx *gorm.DB, batch *models.Batch, items []models.BatchItem) error {
+	for i := range items {
+		items[i].BatchID = batch.ID
+		if err := tx.Create(&items[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	tallyBatch(batch, items)
+	return tx.Model(batch).Updates(map[string]interface{}{
+		"status":    batch.Status,
+		"posted":    batch.Posted,
+		"held":      batch.Held,
+		"duplicate": batch.Duplicate,
+		"failed":    batch.Failed,
+	}).Error
+}
+
+// tallyBatch counts the results of the items and sets the status of the batch from them.
+// A batch fails when an item of an atomic batch or every item failed, and partially fails when only some did.
+func tallyBatch(batch *models.Batch, items []models.BatchItem) {
+	for i := range items {
+		switch items[i].Status {
+		case models.BatchItemStatusPosted:
+			batch.Posted++
+		case models.BatchItemStatusHeld:
+			batch.Held++
+		case models.BatchItemStatusDuplicate:
+			batch.Duplicate++
+		case models.BatchItemStatusFailed:
+			batch.Failed++
+		}
+	}
+
+	switch {
+	case batch.Failed == 0:
+		batch.Status = models.BatchStatusCompleted
+	case batch.Mode == models.BatchModeAtomic || batch.Failed == len(items):
+		batch.Status = models.BatchStatusFailed
+	default:
+		batch.Status = models.BatchStatusPartiallyFailed
+	}
+}
+
+// setKeys returns the keys of a set
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+)
+
+func TestTallyBatch(t *testing.T) {
+	items := func(statuses ...string) []models.BatchItem {
+		items := make([]models.BatchItem, len(statuses))
+		for i, status := range statuses {
+			items[i] = models.BatchItem{Position: i + 1, Status: status}
+		}
+		return items
+	}
+
+	tests := []struct {
+		name  string
+		mode  string
+		items []models.BatchItem
+		want  models.Batch
+	}{
+		{
+			name:  "atomic batch posted in full",
+			mode:  models.BatchModeAtomic,
+			items: items(models.BatchItemStatusPosted, models.BatchItemStatusPosted, models.BatchItemStatusDuplicate),
+			want:  models.Batch{Status: models.BatchStatusCompleted, Posted: 2, Duplicate: 1},
+		},
+		{
+			name:  "atomic batch with a failed item",
+			mode:  models.BatchModeAtomic,
+			items: items(models.BatchItemStatusSkipped, models.BatchItemStatusFailed, models.BatchItemStatusSkipped),
+			want:  models.Batch{Status: models.BatchStatusFailed, Failed: 1},
+		},
+		{
+			name:  "best-effort batch with a failed item",
+			mode:  models.BatchModeBestEffort,
+			items: items(models.BatchItemStatusPosted, models.BatchItemStatusFailed, models.BatchItemStatusHeld),
+			want:  models.Batch{Status: models.BatchStatusPartiallyFailed, Posted: 1, Held: 1, Failed: 1},
+		},
+		{
+			name:  "best-effort batch where every item failed",
+			mode:  models.BatchModeBestEffort,
+			items: items(models.BatchItemStatusFailed, models.BatchItemStatusFailed),
+			want:  models.Batch{Status: models.BatchStatusFailed, Failed: 2},
+		},
+		{
+			name:  "best-effort batch of duplicates and holds",
+			mode:  models.BatchModeBestEffort,
+			items: items(models.BatchItemStatusDuplicate, models.BatchItemStatusHeld),
+			want:  models.Batch{Status: models.BatchStatusCompleted, Held: 1, Duplicate: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := models.Batch{Mode: tt.mode, Status: models.BatchStatusProcessing}
+			tallyBatch(&batch, tt.items)
+
+			tt.want.Mode = tt.mode
+			require.Equal(t, tt.want, batch)
+		})
+	}
+}
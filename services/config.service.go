@@ -2,6 +2,7 @@ package services
 
 import (
 	"github.com/spf13/viper"
+	"ledger-service/logger"
 	"ledger-service/models"
 )
 
@@ -12,6 +13,25 @@ func LoadConfig() {
 	v.AutomaticEnv()
 	v.SetDefault("SERVER_PORT", "8000")
 	v.SetDefault("MODE", "debug")
+	v.SetDefault("SHUTDOWN_TIMEOUT_SECONDS", 15)
+	v.SetDefault("SHUTDOWN_READINESS_DELAY_SECONDS", 5)
+	v.SetDefault("TRACING_EXPORTER", TracingExporterNone)
+	v.SetDefault("TRACING_FILE", "logs/traces.json")
+	v.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_FORMAT", logger.FormatJSON)
+	v.SetDefault("LOG_OUTPUT", LogOutputStdout)
+	v.SetDefault("ACCESS_LOG_FILE", "logs/access.log")
+	v.SetDefault("LOG_MAX_SIZE_MB", 100)
+	v.SetDefault("LOG_MAX_AGE_DAYS", 30)
+	v.SetDefault("LOG_MAX_BACKUPS", 10)
+	v.SetDefault("LOG_COMPRESS", true)
+	v.SetDefault("REQUEST_SIGNING_MAX_SKEW_SECONDS", 300)
+	v.SetDefault("AUTO_CREATE_USERS", false)
+	v.SetDefault("SCHEDULER_ENABLED", true)
+	v.SetDefault("SCHEDULER_INTERVAL_SECONDS", 60)
+	v.SetDefault("FX_QUOTE_TTL_SECONDS", 30)
+	v.SetDefault("BATCH_MAX_ITEMS", 1000)
 	v.SetConfigType("dotenv")
 	v.SetConfigName(".env.local")
 	v.AddConfigPath("./")
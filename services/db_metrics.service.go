@@ -0,0 +1,44 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"ledger-service/metrics"
+	"time"
+)
+
+// dbQueryStartKey stores the start time of an operation on its scope
+const dbQueryStartKey = "metrics:query_start"
+
+// registerDBMetrics observes the latency of every create, query, update, delete and raw row query
+func registerDBMetrics(db *gorm.DB) {
+	callbacks := db.Callback()
+
+	callbacks.Create().Before("gorm:begin_transaction").Register("metrics:before_create", startDBTimer)
+	callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("metrics:after_create", observeDBTimer("create"))
+	callbacks.Update().Before("gorm:begin_transaction").Register("metrics:before_update", startDBTimer)
+	callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("metrics:after_update", observeDBTimer("update"))
+	callbacks.Delete().Before("gorm:begin_transaction").Register("metrics:before_delete", startDBTimer)
+	callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register("metrics:after_delete", observeDBTimer("delete"))
+	callbacks.Query().Before("gorm:query").Register("metrics:before_query", startDBTimer)
+	callbacks.Query().After("gorm:after_query").Register("metrics:after_query", observeDBTimer("query"))
+	callbacks.RowQuery().Before("gorm:row_query").Register("metrics:before_row_query", startDBTimer)
+	callbacks.RowQuery().After("gorm:row_query").Register("metrics:after_row_query", observeDBTimer("row_query"))
+}
+
+func startDBTimer(scope *gorm.Scope) {
+	scope.InstanceSet(dbQueryStartKey, time.Now())
+}
+
+func observeDBTimer(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(dbQueryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		metrics.DBQueryDuration.WithLabelValues(operation, scope.TableName()).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys under which the request context and the current span are stored on a gorm scope
+const (
+	dbContextKey = "tracing:context"
+	dbSpanKey    = "tracing:span"
+)
+
+// dbWithContext returns the connection carrying ctx, so the queries it runs are traced as children of the span in ctx.
+// gorm v1 has no context support, so the context travels as a scope setting.
+func dbWithContext(ctx context.Context) *gorm.DB {
+	return DbConnection.Set(dbContextKey, ctx)
+}
+
+// registerDBTracing creates a span for every create, query, update, delete and raw row query run through dbWithContext
+func registerDBTracing(db *gorm.DB) {
+	callbacks := db.Callback()
+
+	callbacks.Create().Before("gorm:begin_transaction").Register("tracing:before_create", startDBSpan("create"))
+	callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("tracing:after_create", endDBSpan)
+	callbacks.Update().Before("gorm:begin_transaction").Register("tracing:before_update", startDBSpan("update"))
+	callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("tracing:after_update", endDBSpan)
+	callbacks.Delete().Before("gorm:begin_transaction").Register("tracing:before_delete", startDBSpan("delete"))
+	callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register("tracing:after_delete", endDBSpan)
+	callbacks.Query().Before("gorm:query").Register("tracing:before_query", startDBSpan("query"))
+	callbacks.Query().After("gorm:after_query").Register("tracing:after_query", endDBSpan)
+	callbacks.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", startDBSpan("row_query"))
+	callbacks.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", endDBSpan)
+}
+
+func startDBSpan(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.Get(dbContextKey)
+		if !ok {
+			return
+		}
+		ctx, ok := value.(context.Context)
+		if !ok {
+			return
+		}
+
+		table := scope.TableName()
+		_, span := Tracer().Start(ctx, "postgres "+operation+" "+table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperation(operation),
+				semconv.DBSQLTable(table),
+			),
+		)
+		scope.InstanceSet(dbSpanKey, span)
+	}
+}
+
+func endDBSpan(scope *gorm.Scope) {
+	value, ok := scope.InstanceGet(dbSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(semconv.DBStatement(scope.SQL))
+	if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"ledger-service/logger"
+	"ledger-service/metrics"
+	"ledger-service/models"
+	"math"
+	"os"
+)
+
+// feeTier is a band of a tiered fee schedule. It applies to amounts up to UpTo, or to every larger amount when UpTo is 0.
+type feeTier struct {
+	UpTo    float64 `yaml:"up_to"`
+	Flat    float64 `yaml:"flat"`
+	Percent float64 `yaml:"percent"`
+}
+
+// feeSchedule is the fee charged on one kind of transaction: a flat part plus a percentage of the amount,
+// taken from the first matching tier when tiers are set, and kept between Min and Max
+type feeSchedule struct {
+	Flat    float64   `yaml:"flat"`
+	Percent float64   `yaml:"percent"`
+	Tiers   []feeTier `yaml:"tiers"`
+	Min     float64   `yaml:"min"`
+	Max     float64   `yaml:"max"`
+}
+
+// feeScheduleFile is the layout of FEE_SCHEDULE_FILE
+type feeScheduleFile struct {
+	Fees map[string]feeSchedule `yaml:"fees"`
+}
+
+// Global variable with the fee schedules loaded from FEE_SCHEDULE_FILE, by fee type
+var feeSchedules map[string]feeSchedule
+
+// feeTypes are the transaction kinds FEE_SCHEDULE_FILE can list
+var feeTypes = map[string]bool{
+	models.FeeTypeDebit:      true,
+	models.FeeTypeMove:       true,
+	models.FeeTypeConversion: true,
+}
+
+// LoadFeeSchedules loads the fee schedules from FEE_SCHEDULE_FILE. Without a file no fees are charged.
+func LoadFeeSchedules() {
+	feeSchedules = nil
+	if Config.FeeScheduleFile == "" {
+		return
+	}
+
+	content, err := os.ReadFile(Config.FeeScheduleFile)
+	if err != nil {
+		panic(fmt.Errorf("cannot read FEE_SCHEDULE_FILE: %w", err))
+	}
+
+	var file feeScheduleFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		panic(fmt.Errorf("invalid FEE_SCHEDULE_FILE: %w", err))
+	}
+	for feeType, schedule := range file.Fees {
+		if !feeTypes[feeType] {
+			panic(fmt.Errorf("invalid FEE_SCHEDULE_FILE, unknown transaction type %q", feeType))
+		}
+		if err := schedule.validate(); err != nil {
+			panic(fmt.Errorf("invalid %s fee in FEE_SCHEDULE_FILE: %w", feeType, err))
+		}
+	}
+
+	feeSchedules = file.Fees
+	logger.Info("Loaded fee schedules", zap.Int("schedules", len(feeSchedules)))
+}
+
+func (s feeSchedule) validate() error {
+	if s.Flat < 0 || s.Percent < 0 || s.Min < 0 || s.Max < 0 {
+		return errors.New("fees cannot be negative")
+	}
+	if s.Max > 0 && s.Max < s.Min {
+		return errors.New("max cannot be below min")
+	}
+	for i, tier := range s.Tiers {
+		if tier.Flat < 0 || tier.Percent < 0 || tier.UpTo < 0 {
+			return fmt.Errorf("tier %d cannot be negative", i+1)
+		}
+		last := i == len(s.Tiers)-1
+		if tier.UpTo == 0 && !last {
+			return errors.New("only the last tier can be without up_to")
+		}
+		if i > 0 && !(tier.UpTo == 0 && last) && tier.UpTo <= s.Tiers[i-1].UpTo {
+			return fmt.Errorf("tier %d must have a larger up_to than the tier before", i+1)
+		}
+	}
+	return nil
+}
+
+// calculateFee returns the fee on an amount for the fee type, or nil when no fee is charged
+func calculateFee(feeType string, amount float64) *models.Fee {
+	schedule, ok := feeSchedules[feeType]
+	if !ok {
+		return nil
+	}
+
+	flat, percent := schedule.Flat, schedule.Percent
+	if len(schedule.Tiers) > 0 {
+		// Amounts above the last bounded tier pay the rate of that tier
+		tier := schedule.Tiers[len(schedule.Tiers)-1]
+		for _, t := range schedule.Tiers {
+			if t.UpTo == 0 || amount <= t.UpTo {
+				tier = t
+				break
+			}
+		}
+		flat, percent = tier.Flat, tier.Percent
+	}
+
+	fee := models.Fee{Flat: flat, Percentage: roundCents(amount * percent / 100)}
+	fee.Amount = roundCents(fee.Flat + fee.Percentage)
+	if fee.Amount < schedule.Min {
+		fee.Amount = schedule.Min
+		fee.Capped = "min"
+	}
+	if schedule.Max > 0 && fee.Amount > schedule.Max {
+		fee.Amount = schedule.Max
+		fee.Capped = "max"
+	}
+	if fee.Amount <= 0 {
+		return nil
+	}
+	return &fee
+}
+
+// roundCents rounds an amount to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// chargesFee tells whether transactions of the UID are charged fees. The fee account itself pays none.
+func chargesFee(uid string) bool {
+	return len(feeSchedules) > 0 && uid != Config.FeeAccountUID
+}
+
+// lockFeeAccount acquires the lock of the wallet of the fee account in the currency and returns the wallet.
+// It is always taken after the locks of the paying wallets, so fees cannot deadlock with each other.
+func lockFeeAccount(ctx context.Context, logCtx context.Context, db *gorm.DB, currency string) (*redsync.Mutex, models.Wallet, error) {
+	var account models.User
+	if err := db.Where("uid = ?", Config.FeeAccountUID).First(&account).Error; err != nil {
+		return nil, models.Wallet{}, classifyDBError(err, "Fee account not found")
+	}
+	wallet, err := findCurrencyWallet(db, account, currency)
+	if err != nil {
+		return nil, models.Wallet{}, err
+	}
+
+	mutex, err := lockWallet(ctx, logCtx, account.UID, wallet.Name)
+	if err != nil {
+		return nil, models.Wallet{}, err
+	}
+	if err := reloadLocked(db, &account, &wallet); err != nil {
+		releaseLock(mutex)
+		return nil, models.Wallet{}, classifyDBError(err, "Fee account not found")
+	}
+	if err := checkFeeAccount(account); err != nil {
+		releaseLock(mutex)
+		return nil, models.Wallet{}, err
+	}
+	return mutex, wallet, nil
+}
+
+// checkFeeAccount rejects fees while the fee account cannot receive them
+func checkFeeAccount(account models.User) error {
+	switch {
+	case account.Status == models.UserStatusClosed:
+		return models.NewError(models.ErrAccountClosed, "Fee account is closed")
+	case account.Status == models.UserStatusFrozen && account.CreditsBlocked:
+		return models.NewError(models.ErrAccountFrozen, "Fee account is frozen")
+	}
+	return nil
+}
+
+// postFee charges the fee to the paying wallet and credits it to the fee account, linked to the transaction it was charged on
+func postFee(tx *gorm.DB, payer *models.Wallet, feeWallet *models.Wallet, fee *models.Fee, transactionID string) error {
+	fee.TransactionID = transactionID + ":fee"
+	transactions := []models.Transaction{
+		{UserID: payer.UserID, WalletID: payer.ID, Amount: fee.Amount, Type: models.TransactionTypeFee, TransactionID: fee.TransactionID, RelatedTransactionID: transactionID, Currency: payer.Currency},
+		{UserID: feeWallet.UserID, WalletID: feeWallet.ID, Amount: fee.Amount, Type: models.TransactionTypeFeeIncome, TransactionID: transactionID + ":fee_income", RelatedTransactionID: transactionID, Currency: feeWallet.Currency},
+	}
+	for i := range transactions {
+		if err := tx.Create(&transactions[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	payer.Balance -= fee.Amount
+	feeWallet.Balance += fee.Amount
+	if err := tx.Model(payer).Update("balance", payer.Balance).Error; err != nil {
+		return err
+	}
+	return tx.Model(feeWallet).Update("balance", feeWallet.Balance).Error
+}
+
+// chargedFee records a fee that was written and drops the cached balance of the fee account wallet
+func chargedFee(ctx context.Context, logCtx context.Context, feeType string, fee *models.Fee, feeWallet models.Wallet) {
+	logger.InfoCtx(logCtx, "Fee charged", zap.String("fee_transaction_id", fee.TransactionID), zap.Float64("fee", fee.Amount))
+	metrics.FeesCharged.WithLabelValues(feeType).Add(fee.Amount)
+	invalidateBalanceCache(ctx, logCtx, Config.FeeAccountUID, feeWallet.Name)
+}
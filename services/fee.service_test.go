@@ -0,0 +1,126 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withFeeSchedules sets the fee schedules for a test and restores the loaded ones after it
+func withFeeSchedules(t *testing.T, schedules map[string]feeSchedule) {
+	loaded := feeSchedules
+	feeSchedules = schedules
+	t.Cleanup(func() { feeSchedules = loaded })
+}
+
+func TestCalculateFee(t *testing.T) {
+	withFeeSchedules(t, map[string]feeSchedule{
+		models.FeeTypeDebit: {
+			Tiers: []feeTier{
+				{UpTo: 100, Flat: 0.10},
+				{UpTo: 1000, Flat: 0.10, Percent: 0.2},
+				{Percent: 0.1},
+			},
+			Min: 0.25,
+			Max: 10,
+		},
+		"flat": {Flat: 1.5},
+		"bounded": {
+			Tiers: []feeTier{{UpTo: 100, Percent: 1}, {UpTo: 500, Percent: 2}},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		feeType string
+		amount  float64
+		want    *models.Fee
+	}{
+		{"first tier raised to the minimum", models.FeeTypeDebit, 50, &models.Fee{Amount: 0.25, Flat: 0.10, Capped: "min"}},
+		{"upper bound of the first tier", models.FeeTypeDebit, 100, &models.Fee{Amount: 0.25, Flat: 0.10, Capped: "min"}},
+		{"second tier", models.FeeTypeDebit, 500, &models.Fee{Amount: 1.10, Flat: 0.10, Percentage: 1}},
+		{"unbounded last tier", models.FeeTypeDebit, 5000, &models.Fee{Amount: 5, Percentage: 5}},
+		{"last tier lowered to the maximum", models.FeeTypeDebit, 20000, &models.Fee{Amount: 10, Percentage: 20, Capped: "max"}},
+		{"percentage rounded to cents", models.FeeTypeDebit, 333.33, &models.Fee{Amount: 0.77, Flat: 0.10, Percentage: 0.67}},
+		{"flat fee without tiers", "flat", 250, &models.Fee{Amount: 1.5, Flat: 1.5}},
+		{"above the last bounded tier", "bounded", 1000, &models.Fee{Amount: 20, Percentage: 20}},
+		{"fee below a cent", "bounded", 0.2, nil},
+		{"type without a schedule", "move", 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, calculateFee(tt.feeType, tt.amount))
+		})
+	}
+}
+
+func TestFeeScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule feeSchedule
+		wantErr  string
+	}{
+		{"flat and percentage", feeSchedule{Flat: 0.25, Percent: 1, Min: 0.5, Max: 10}, ""},
+		{"tiers with an unbounded last tier", feeSchedule{Tiers: []feeTier{{UpTo: 100, Flat: 1}, {Percent: 1}}}, ""},
+		{"max left unbounded", feeSchedule{Min: 5}, ""},
+		{"negative flat", feeSchedule{Flat: -1}, "fees cannot be negative"},
+		{"negative max", feeSchedule{Max: -1}, "fees cannot be negative"},
+		{"max below min", feeSchedule{Min: 5, Max: 1}, "max cannot be below min"},
+		{"negative tier", feeSchedule{Tiers: []feeTier{{UpTo: 100, Percent: -1}}}, "tier 1 cannot be negative"},
+		{"unbounded tier before the last", feeSchedule{Tiers: []feeTier{{Flat: 1}, {UpTo: 100, Flat: 2}}}, "only the last tier can be without up_to"},
+		{"tiers out of order", feeSchedule{Tiers: []feeTier{{UpTo: 100}, {UpTo: 100}}}, "tier 2 must have a larger up_to than the tier before"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadFeeSchedules(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantTypes []string
+		wantPanic bool
+	}{
+		{"debit, move and conversion", "fees:\n  debit: {flat: 1}\n  move: {percent: 0.5}\n  conversion: {flat: 0.25, max: 5}\n", []string{models.FeeTypeDebit, models.FeeTypeMove, models.FeeTypeConversion}, false},
+		{"moves only", "fees:\n  move: {flat: 1}\n", []string{models.FeeTypeMove}, false},
+		{"credits are always free", "fees:\n  credit: {flat: 1}\n", nil, true},
+		{"invalid schedule", "fees:\n  conversion: {min: 5, max: 1}\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fee_schedule.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			withConfig(t, models.EnvConfig{FeeScheduleFile: path})
+			withFeeSchedules(t, nil)
+
+			if tt.wantPanic {
+				require.Panics(t, LoadFeeSchedules)
+				return
+			}
+			LoadFeeSchedules()
+			require.Len(t, feeSchedules, len(tt.wantTypes))
+			for _, feeType := range tt.wantTypes {
+				require.Contains(t, feeSchedules, feeType)
+			}
+		})
+	}
+}
+
+func TestLoadFeeSchedulesWithoutFile(t *testing.T) {
+	withConfig(t, models.EnvConfig{})
+	withFeeSchedules(t, nil)
+
+	LoadFeeSchedules()
+	require.Nil(t, calculateFee(models.FeeTypeMove, 100))
+	require.Nil(t, calculateFee(models.FeeTypeConversion, 100))
+}
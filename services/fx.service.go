@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"os"
+	"time"
+)
+
+// fxRatesFile is the layout of FX_RATES_FILE
+type fxRatesFile struct {
+	Rates []models.FxRateRequest `yaml:"rates"`
+}
+
+// LoadFxRates stores the rates listed in FX_RATES_FILE in the rates table, replacing rates of the same pairs.
+// Rates set through the admin API for other pairs are kept. Without a file the table is left as it is.
+func LoadFxRates() {
+	if Config.FxRatesFile == "" {
+		return
+	}
+
+	content, err := os.ReadFile(Config.FxRatesFile)
+	if err != nil {
+		panic(fmt.Errorf("cannot read FX_RATES_FILE: %w", err))
+	}
+
+	var file fxRatesFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		panic(fmt.Errorf("invalid FX_RATES_FILE: %w", err))
+	}
+	for i, rate := range file.Rates {
+		if err := rate.Validate(); err != nil {
+			panic(fmt.Errorf("invalid rate %d in FX_RATES_FILE: %w", i+1, err))
+		}
+	}
+
+	if err := storeFxRates(DbConnection, file.Rates, "file:"+Config.FxRatesFile); err != nil {
+		panic(fmt.Errorf("cannot store the rates of FX_RATES_FILE: %w", err))
+	}
+	logger.Info("Loaded FX rates", zap.Int("rates", len(file.Rates)))
+}
+
+// SetFxRates creates or replaces the rates of the currency pairs in one database transaction
+func SetFxRates(ctx context.Context, request models.SetFxRatesRequest, actor string) ([]models.FxRate, error) {
+	if err := storeFxRates(dbWithContext(ctx), request.Rates, actor); err != nil {
+		return nil, classifyDBError(err, "Rate not found")
+	}
+
+	logger.InfoCtx(ctx, "FX rates set", zap.Int("rates", len(request.Rates)), zap.String("actor", actor))
+	return ListFxRates(ctx)
+}
+
+// storeFxRates upserts the rates by currency pair
+func storeFxRates(db *gorm.DB, rates []models.FxRateRequest, updatedBy string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, rate := range rates {
+			var stored models.FxRate
+			err := tx.Where(models.FxRate{Base: rate.Base, Quote: rate.Quote}).
+				Assign(map[string]interface{}{
+					"rate":       rate.Rate,
+					"spread":     rate.Spread,
+					"source":     rate.Source,
+					"updated_by": updatedBy,
+				}).
+				FirstOrCreate(&stored).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListFxRates returns every rate by currency pair
+func ListFxRates(ctx context.Context) ([]models.FxRate, error) {
+	var rates []models.FxRate
+	if err := dbWithContext(ctx).Order("base, quote").Find(&rates).Error; err != nil {
+		return nil, classifyDBError(err, "Rates not found")
+	}
+	return rates, nil
+}
+
+// CreateFxQuote prices a conversion from a wallet of the user to another of its wallets and keeps the price
+// for FX_QUOTE_TTL_SECONDS, so a conversion made with the quote before it expires gets exactly that price
+func CreateFxQuote(ctx context.Context, uid string, fromWallet string, request models.ConvertFundsRequest) (models.FxConversion, error) {
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.Where("uid = ?", uid).First(&user).Error; err != nil {
+		return models.FxConversion{}, classifyDBError(err, "User not found")
+	}
+	from, to, err := findConversionWallets(db, user, fromWallet, request)
+	if err != nil {
+		return models.FxConversion{}, err
+	}
+	quote, err := priceConversion(db, user, from, to, request.Amount)
+	if err != nil {
+		return models.FxConversion{}, err
+	}
+
+	ttl := time.Duration(Config.FxQuoteTTLSeconds) * time.Second
+	expiresAt := time.Now().Add(ttl).UTC()
+	quote.QuoteID = uuid.New().String()
+	quote.ExpiresAt = &expiresAt
+	data, err := json.Marshal(quote)
+	if err != nil {
+		return models.FxConversion{}, err
+	}
+	if err := GetRedisDefaultClient().Set(ctx, "fx_quote:"+quote.QuoteID, data, ttl).Err(); err != nil {
+		logger.ErrorCtx(ctx, "Error storing FX quote", zap.Error(err))
+		return models.FxConversion{}, models.WrapError(models.ErrDependencyUnavailable, "Quote store is unavailable", err)
+	}
+	return quote, nil
+}
+
+// ConvertFunds converts an amount from a wallet of the user to another of its wallets in a different currency,
+// at the rate of the quote when one is given or else at the current rate, with the conversion fee charged to the source wallet.
+// When a risk rule holds a conversion without a quote for review, nothing is written and the hold is returned instead;
+// a quoted conversion a rule would hold is denied.
+func ConvertFunds(ctx context.Context, uid string, fromWallet string, request models.ConvertFundsRequest) (models.Posting, error) {
+	return postConversion(ctx, uid, fromWallet, request, uuid.New().String(), false)
+}
+
+// postConversion converts funds with the conversion ID. The risk rules are skipped for conversions an operator approved.
+// Both wallet locks are held while the two legs are recorded in one database transaction.
+func postConversion(ctx context.Context, uid string, fromWallet string, request models.ConvertFundsRequest, conversionID string, approved bool) (models.Posting, error) {
+	if err := checkTransactionAmount(request.Amount); err != nil {
+		return models.Posting{}, err
+	}
+
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.Posting{}, err
+	}
+	defer done()
+
+	db := dbWithContext(ctx)
+	redisClient := GetRedisDefaultClient()
+
+	var user models.User
+	if err := db.Where("uid = ?", uid).First(&user).Error; err != nil {
+		return models.Posting{}, classifyDBError(err, "User not found")
+	}
+	from, to, err := findConversionWallets(db, user, fromWallet, request)
+	if err != nil {
+		return models.Posting{}, err
+	}
+
+	logCtx := logger.WithFields(ctx, zap.String("transaction_id", conversionID), zap.String("wallet", fromWallet), zap.String("to_wallet", request.ToWallet))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("ledger.transaction_id", conversionID))
+
+	var conversion models.FxConversion
+	if request.QuoteID != "" {
+		conversion, err = loadFxQuote(ctx, request.QuoteID)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		if conversion.UID != uid || conversion.FromWallet != fromWallet || conversion.ToWallet != request.ToWallet || conversion.Amount != request.Amount {
+			return models.Posting{}, models.NewError(models.ErrValidation, "Quote was made for another conversion")
+		}
+	} else {
+		conversion, err = priceConversion(db, user, from, to, request.Amount)
+		if err != nil {
+			return models.Posting{}, err
+		}
+	}
+
+	mutexes, err := lockWallets(ctx, logCtx, uid, []string{fromWallet, request.ToWallet})
+	if err != nil {
+		return models.Posting{}, err
+	}
+	defer releaseLocks(mutexes)
+
+	if err := reloadLocked(db, &user, &from, &to); err != nil {
+		logger.ErrorCtx(logCtx, "Error reloading wallets", zap.Error(err))
+		return models.Posting{}, classifyDBError(err, "Wallet not found")
+	}
+	if err := checkDebit(user); err != nil {
+		return models.Posting{}, err
+	}
+	// The fee is charged to the source wallet in its currency, on top of the amount converted
+	var fee *models.Fee
+	required := request.Amount
+	if chargesFee(uid) {
+		fee = calculateFee(models.FeeTypeConversion, request.Amount)
+	}
+	if fee != nil {
+		required += fee.Amount
+	}
+	if availableFunds(user, from) < required {
+		return models.Posting{}, models.NewError(models.ErrInsufficientFunds, "Insufficient funds in the wallet")
+	}
+	if !approved {
+		input := models.RiskInput{User: user, Wallet: fromWallet, ToWallet: request.ToWallet, Type: models.TransactionTypeFxOut, Amount: request.Amount, Metadata: request.Metadata}
+		decision, err := evaluateRisk(db, logCtx, input)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		// A quote only holds its price for FX_QUOTE_TTL_SECONDS, so a quoted conversion cannot wait for a review
+		if decision != nil && decision.Action == models.RiskActionHold && request.QuoteID != "" {
+			return models.Posting{}, models.NewError(models.ErrRiskDenied, "Transaction would be held for review: "+decision.Reason)
+		}
+		if decision != nil {
+			hold, err := applyRiskDecision(db, input, decision, conversionID)
+			return models.Posting{Hold: hold}, err
+		}
+	}
+
+	var feeWallet models.Wallet
+	if fee != nil {
+		var feeMutex *redsync.Mutex
+		feeMutex, feeWallet, err = lockFeeAccount(ctx, logCtx, db, from.Currency)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		defer releaseLock(feeMutex)
+	}
+
+	// A quote is used once: it is deleted right before the conversion is written, so a concurrent request cannot use it too
+	if request.QuoteID != "" {
+		deleted, err := redisClient.Del(ctx, "fx_quote:"+request.QuoteID).Result()
+		if err != nil {
+			logger.ErrorCtx(logCtx, "Error deleting FX quote", zap.Error(err))
+			return models.Posting{}, models.WrapError(models.ErrDependencyUnavailable, "Quote store is unavailable", err)
+		}
+		if deleted == 0 {
+			return models.Posting{}, models.NewError(models.ErrConflict, "Quote has expired or was already used")
+		}
+	}
+
+	conversion.TransactionID = conversionID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := postConversionLegs(tx, &from, &to, conversion); err != nil {
+			return err
+		}
+		if fee != nil {
+			return postFee(tx, &from, &feeWallet, fee, conversionID)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorCtx(logCtx, "Error converting funds", zap.Error(err))
+		return models.Posting{}, classifyDBError(err, "Wallet not found")
+	}
+
+	logger.InfoCtx(logCtx, "Funds converted",
+		zap.Float64("amount", conversion.Amount),
+		zap.Float64("converted_amount", conversion.ConvertedAmount),
+		zap.Float64("rate", conversion.Rate),
+		zap.String("quote_id", conversion.QuoteID),
+	)
+	invalidateBalanceCache(ctx, logCtx, uid, fromWallet)
+	invalidateBalanceCache(ctx, logCtx, uid, request.ToWallet)
+	if fee != nil {
+		chargedFee(ctx, logCtx, models.FeeTypeConversion, fee, feeWallet)
+	}
+
+	return models.Posting{TransactionID: conversionID, Fee: fee, Conversion: &conversion}, nil
+}
+
+// postConversionLegs records the debit in the source currency and the credit in the destination currency,
+// both with the rate, spread and source of the conversion, and updates both balances
+func postConversionLegs(tx *gorm.DB, from *models.Wallet, to *models.Wallet, conversion models.FxConversion) error {
+	transactions := []models.Transaction{
+		{UserID: from.UserID, WalletID: from.ID, Amount: conversion.Amount, Type: models.TransactionTypeFxOut, TransactionID: conversion.TransactionID + ":debit", Currency: from.Currency},
+		{UserID: to.UserID, WalletID: to.ID, Amount: conversion.ConvertedAmount, Type: models.TransactionTypeFxIn, TransactionID: conversion.TransactionID + ":credit", Currency: to.Currency},
+	}
+	for i := range transactions {
+		transactions[i].FxRate = conversion.Rate
+		transactions[i].FxSpread = conversion.Spread
+		transactions[i].FxSource = conversion.Source
+		if err := tx.Create(&transactions[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	from.Balance -= conversion.Amount
+	to.Balance += conversion.ConvertedAmount
+	if err := tx.Model(from).Update("balance", from.Balance).Error; err != nil {
+		return err
+	}
+	return tx.Model(to).Update("balance", to.Balance).Error
+}
+
+// findConversionWallets returns the source and destination wallets of a conversion, which have to be in different currencies
+func findConversionWallets(db *gorm.DB, user models.User, fromWallet string, request models.ConvertFundsRequest) (models.Wallet, models.Wallet, error) {
+	if request.Amount <= 0 {
+		return models.Wallet{}, models.Wallet{}, models.NewError(models.ErrValidation, "Amount must be positive")
+	}
+	if fromWallet == request.ToWallet {
+		return models.Wallet{}, models.Wallet{}, models.NewError(models.ErrValidation, "Funds can only be converted to another wallet")
+	}
+	from, err := findWallet(db, user, fromWallet)
+	if err != nil {
+		return models.Wallet{}, models.Wallet{}, err
+	}
+	to, err := findWallet(db, user, request.ToWallet)
+	if err != nil {
+		return models.Wallet{}, models.Wallet{}, err
+	}
+	if from.Currency == to.Currency {
+		return models.Wallet{}, models.Wallet{}, models.NewError(models.ErrValidation, "Wallets are in the same currency, move the funds instead")
+	}
+	return from, to, nil
+}
+
+// priceConversion prices an amount at the current rate of the pair less its spread, rounding the converted amount to cents
+func priceConversion(db *gorm.DB, user models.User, from models.Wallet, to models.Wallet, amount float64) (models.FxConversion, error) {
+	rate, spread, source, err := findFxRate(db, from.Currency, to.Currency)
+	if err != nil {
+		return models.FxConversion{}, err
+	}
+
+	applied := rate * (1 - spread/100)
+	conversion := models.FxConversion{
+		UID:             user.UID,
+		FromWallet:      from.Name,
+		ToWallet:        to.Name,
+		FromCurrency:    from.Currency,
+		ToCurrency:      to.Currency,
+		Amount:          amount,
+		ConvertedAmount: roundCents(amount * applied),
+		Rate:            applied,
+		Spread:          spread,
+		Source:          source,
+	}
+	if conversion.ConvertedAmount <= 0 {
+		return models.FxConversion{}, models.NewError(models.ErrValidation, "Amount is too small to convert")
+	}
+	return conversion, nil
+}
+
+// findFxRate returns the mid rate from one currency to another with its spread and source,
+// derived from the inverse pair when only that one is set
+func findFxRate(db *gorm.DB, from string, to string) (float64, float64, string, error) {
+	var rate models.FxRate
+	err := db.Where("(base = ? AND quote = ?) OR (base = ? AND quote = ?)", from, to, to, from).
+		Order(gorm.Expr("base <> ?", from)).
+		First(&rate).Error
+	if err != nil {
+		return 0, 0, "", classifyDBError(err, "No FX rate from "+from+" to "+to)
+	}
+	mid, spread, source := rateFrom(rate, from)
+	return mid, spread, source, nil
+}
+
+// rateFrom returns the mid rate of a stored pair from the currency with its spread and source,
+// inverting it when the pair is stored the other way round. The source is who set the rate when it has none.
+func rateFrom(rate models.FxRate, from string) (float64, float64, string) {
+	source := rate.Source
+	if source == "" {
+		source = rate.UpdatedBy
+	}
+	if rate.Base != from {
+		return 1 / rate.Rate, rate.Spread, source
+	}
+	return rate.Rate, rate.Spread, source
+}
+
+// loadFxQuote returns a quote that has not expired
+func loadFxQuote(ctx context.Context, quoteID string) (models.FxConversion, error) {
+	data, err := GetRedisDefaultClient().Get(ctx, "fx_quote:"+quoteID).Bytes()
+	if err == redis.Nil {
+		return models.FxConversion{}, models.NewError(models.ErrConflict, "Quote has expired or was already used")
+	}
+	if err != nil {
+		logger.ErrorCtx(ctx, "Error loading FX quote", zap.Error(err))
+		return models.FxConversion{}, models.WrapError(models.ErrDependencyUnavailable, "Quote store is unavailable", err)
+	}
+
+	var quote models.FxConversion
+	if err := json.Unmarshal(data, &quote); err != nil {
+		return models.FxConversion{}, models.WrapError(models.ErrDependencyUnavailable, "Quote store is unavailable", err)
+	}
+	return quote, nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+)
+
+func TestRateFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       models.FxRate
+		from       string
+		wantRate   float64
+		wantSpread float64
+		wantSource string
+	}{
+		{"stored pair", models.FxRate{Base: "EUR", Quote: "USD", Rate: 1.25, Spread: 0.5, Source: "ECB"}, "EUR", 1.25, 0.5, "ECB"},
+		{"inverse pair", models.FxRate{Base: "EUR", Quote: "USD", Rate: 1.25, Spread: 0.5, Source: "ECB"}, "USD", 0.8, 0.5, "ECB"},
+		{"inverse pair without a source", models.FxRate{Base: "GBP", Quote: "JPY", Rate: 200, UpdatedBy: "admin@ledger.local"}, "JPY", 0.005, 0, "admin@ledger.local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, spread, source := rateFrom(tt.rate, tt.from)
+			require.InDelta(t, tt.wantRate, rate, 1e-12)
+			require.Equal(t, tt.wantSpread, spread)
+			require.Equal(t, tt.wantSource, source)
+		})
+	}
+}
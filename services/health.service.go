@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ledger-service/models"
+	"time"
+)
+
+// healthCheckTimeout bounds every dependency check so a hanging dependency cannot hang the probe
+const healthCheckTimeout = 2 * time.Second
+
+// CheckReadiness checks Postgres, Redis when enabled and the migration status.
+// The instance is reported down while it is shutting down, even if every dependency is up.
+func CheckReadiness(ctx context.Context) models.Readiness {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	readiness := models.Readiness{
+		Status:       models.HealthStatusUp,
+		Dependencies: map[string]models.DependencyHealth{},
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"postgres":   pingDB,
+		"migrations": checkMigrations,
+	}
+	if Config.UseRedis {
+		checks["redis"] = pingRedis
+	}
+
+	for name, check := range checks {
+		health := checkDependency(ctx, check)
+		if health.Status != models.HealthStatusUp {
+			readiness.Status = models.HealthStatusDown
+		}
+		readiness.Dependencies[name] = health
+	}
+
+	if IsShuttingDown() {
+		readiness.Status = models.HealthStatusDown
+	}
+
+	return readiness
+}
+
+// checkDependency runs the check and measures its latency
+func checkDependency(ctx context.Context, check func(ctx context.Context) error) models.DependencyHealth {
+	start := time.Now()
+	err := check(ctx)
+	health := models.DependencyHealth{
+		Status:    models.HealthStatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		health.Status = models.HealthStatusDown
+		health.Error = err.Error()
+	}
+	return health
+}
+
+func pingDB(ctx context.Context) error {
+	if DbConnection == nil {
+		return errors.New("not connected")
+	}
+	return DbConnection.DB().PingContext(ctx)
+}
+
+func pingRedis(ctx context.Context) error {
+	return GetRedisDefaultClient().Ping(ctx).Err()
+}
+
+// checkMigrations verifies that the table of every migrated model exists
+func checkMigrations(ctx context.Context) error {
+	if DbConnection == nil {
+		return errors.New("not connected")
+	}
+	for _, model := range migratedModels {
+		if !DbConnection.HasTable(model) {
+			return fmt.Errorf("table for %T is missing", model)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Global variable with the annual interest rates in percent loaded from INTEREST_RATES, by wallet name
+var interestRates map[string]float64
+
+// LoadInterestRates loads the wallet=rate entries listed in INTEREST_RATES.
+// Rates are annual percentages; wallets without a rate earn no interest.
+func LoadInterestRates() {
+	rates := map[string]float64{}
+	for _, entry := range Config.InterestRates {
+		wallet, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || wallet == "" {
+			panic(fmt.Errorf("invalid INTEREST_RATES entry %q, expected wallet=rate", entry))
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			panic(fmt.Errorf("invalid INTEREST_RATES entry %q, the rate must be a non-negative number", entry))
+		}
+		if rate > 0 {
+			rates[wallet] = rate
+		}
+	}
+
+	interestRates = rates
+}
+
+// pendingInterest is the interest accrued by a wallet that was not posted yet
+type pendingInterest struct {
+	UserID   uint
+	WalletID uint
+}
+
+// accrualWallet is a wallet with a rate and its balance when the accruals are made
+type accrualWallet struct {
+	ID      uint
+	UserID  uint
+	Name    string
+	Balance float64
+}
+
+// accrueInterest records the interest every wallet with a rate earned on the day, at the rate over 365 days on its
+// balance at the end of the day. The balance is derived from the current one and the transactions written since,
+// so days accrued late after a scheduler outage get the balance of that day. Wallets that already accrued for the
+// day are skipped, so it can be rerun.
+func accrueInterest(ctx context.Context, day time.Time) error {
+	wallets := make([]string, 0, len(interestRates))
+	for wallet := range interestRates {
+		wallets = append(wallets, wallet)
+	}
+
+	accrued := 0
+	err := dbWithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The balances and the transactions after the day are read from the same snapshot
+		if err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ").Error; err != nil {
+			return err
+		}
+
+		var balances []accrualWallet
+		err := tx.Table("wallets").
+			Select("wallets.id, wallets.user_id, wallets.name, wallets.balance").
+			Joins("JOIN users ON users.id = wallets.user_id").
+			Where("wallets.name IN (?) AND wallets.deleted_at IS NULL AND users.deleted_at IS NULL AND users.status <> ?", wallets, models.UserStatusClosed).
+			Scan(&balances).Error
+		if err != nil {
+			return err
+		}
+
+		var later []models.Transaction
+		err = tx.Select("transactions.wallet_id, transactions.type, transactions.amount, transactions.created_at").
+			Joins("JOIN wallets ON wallets.id = transactions.wallet_id").
+			Where("wallets.name IN (?) AND transactions.created_at >= ?", wallets, day.AddDate(0, 0, 1)).
+			Find(&later).Error
+		if err != nil {
+			return err
+		}
+		laterByWallet := map[uint][]models.Transaction{}
+		for _, transaction := range later {
+			laterByWallet[transaction.WalletID] = append(laterByWallet[transaction.WalletID], transaction)
+		}
+
+		for _, wallet := range balances {
+			balance := balanceAtEndOf(day, wallet.Balance, laterByWallet[wallet.ID])
+			if balance <= 0 {
+				continue
+			}
+			rate := interestRates[wallet.Name]
+			err := tx.Exec(`
+				INSERT INTO interest_accruals (created_at, updated_at, user_id, wallet_id, day, balance, rate, amount, transaction_id)
+				VALUES (now(), now(), ?, ?, ?, ?, ?, ?, '')
+				ON CONFLICT (wallet_id, day) DO NOTHING`,
+				wallet.UserID, wallet.ID, day, balance, rate, balance*rate/36500,
+			).Error
+			if err != nil {
+				return err
+			}
+			accrued++
+		}
+		return nil
+	})
+	if err != nil {
+		return classifyDBError(err, "Wallets not found")
+	}
+
+	logger.InfoCtx(ctx, "Interest accrued", zap.String("day", day.Format("2006-01-02")), zap.Int("wallets", accrued))
+	return nil
+}
+
+// balanceAtEndOf returns the balance a wallet had at the end of the day from its balance now and the transactions
+// written to it since: money that came in after the day is taken out again and money that went out is added back
+func balanceAtEndOf(day time.Time, balance float64, later []models.Transaction) float64 {
+	dayEnd := day.AddDate(0, 0, 1)
+	for _, transaction := range later {
+		if transaction.CreatedAt.Before(dayEnd) {
+			continue
+		}
+		if models.IsOutgoing(transaction.Type) {
+			balance += transaction.Amount
+		} else {
+			balance -= transaction.Amount
+		}
+	}
+	return roundCents(balance)
+}
+
+// postInterest credits every wallet with the interest it accrued before the month start as one interest transaction.
+// Interest that cannot be credited yet, such as to a frozen account, stays pending and is posted the next month.
+func postInterest(ctx context.Context, monthStart time.Time) error {
+	db := dbWithContext(ctx)
+	period := monthStart.AddDate(0, -1, 0).Format("2006-01")
+
+	var pending []pendingInterest
+	err := db.Model(&models.InterestAccrual{}).
+		Select("DISTINCT user_id, wallet_id").
+		Where("transaction_id = '' AND day < ?", monthStart).
+		Scan(&pending).Error
+	if err != nil {
+		return classifyDBError(err, "Interest accruals not found")
+	}
+
+	for _, interest := range pending {
+		err := postWalletInterest(ctx, interest, monthStart, period)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, models.ErrDependencyUnavailable) || errors.Is(err, models.ErrLockTimeout) {
+			return err
+		}
+		logger.ErrorCtx(ctx, "Interest not posted", zap.Uint("wallet_id", interest.WalletID), zap.Error(err))
+	}
+	return nil
+}
+
+// postWalletInterest credits a wallet with its pending interest under the wallet lock.
+// The accruals are marked with the transaction in the same database transaction, so they are never posted twice.
+func postWalletInterest(ctx context.Context, interest pendingInterest, monthStart time.Time, period string) error {
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.First(&user, interest.UserID).Error; err != nil {
+		return classifyDBError(err, "User not found")
+	}
+	var wallet models.Wallet
+	if err := db.First(&wallet, interest.WalletID).Error; err != nil {
+		return classifyDBError(err, "Wallet not found")
+	}
+
+	transactionID := fmt.Sprintf("interest:%d:%s", wallet.ID, period)
+	logCtx := logger.WithFields(ctx, zap.String("transaction_id", transactionID), zap.String("uid", user.UID), zap.String("wallet", wallet.Name))
+
+	mutex, err := lockWallet(ctx, logCtx, user.UID, wallet.Name)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(mutex)
+
+	if err := reloadLocked(db, &user, &wallet); err != nil {
+		return classifyDBError(err, "Wallet not found")
+	}
+	if err := checkCredit(user); err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return classifyDBError(tx.Error, "Wallet not found")
+	}
+	amount, err := claimAccruals(tx, wallet.ID, monthStart, transactionID)
+	if err == nil && amount <= 0 {
+		// Less than a cent is carried over to the next month
+		tx.Rollback()
+		return nil
+	}
+	if err == nil {
+		err = tx.Create(&models.Transaction{
+			UserID:        user.ID,
+			WalletID:      wallet.ID,
+			Amount:        amount,
+			Type:          models.TransactionTypeInterest,
+			TransactionID: transactionID,
+			Currency:      wallet.Currency,
+		}).Error
+	}
+	if err == nil {
+		wallet.Balance += amount
+		err = tx.Model(&wallet).Update("balance", wallet.Balance).Error
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		logger.ErrorCtx(logCtx, "Error posting interest", zap.Error(err))
+		return classifyDBError(err, "Wallet not found")
+	}
+
+	logger.InfoCtx(logCtx, "Interest posted", zap.Float64("amount", amount))
+	invalidateBalanceCache(ctx, logCtx, user.UID, wallet.Name)
+	return nil
+}
+
+// claimAccruals marks the pending accruals of the wallet before the month start with the transaction
+// and returns their sum rounded to cents
+func claimAccruals(tx *gorm.DB, walletID uint, monthStart time.Time, transactionID string) (float64, error) {
+	rows, err := tx.Raw(
+		"UPDATE interest_accruals SET transaction_id = ?, updated_at = now() WHERE wallet_id = ? AND transaction_id = '' AND day < ? RETURNING amount",
+		transactionID, walletID, monthStart,
+	).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var sum float64
+	for rows.Next() {
+		var amount float64
+		if err := rows.Scan(&amount); err != nil {
+			return 0, err
+		}
+		sum += amount
+	}
+	return roundCents(sum), rows.Err()
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+	"time"
+)
+
+func TestBalanceAtEndOf(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+	}
+	at := func(d int, hour int) gorm.Model {
+		return gorm.Model{CreatedAt: time.Date(2026, 10, d, hour, 0, 0, 0, time.UTC)}
+	}
+
+	// The scheduler was down from Oct 15 to Oct 19: the wallet held 200 on the 15th, paid 50 on the 16th,
+	// moved 150 out on the 17th and was funded with 300 today, the 19th
+	later := []models.Transaction{
+		{Model: at(15, 9), Type: models.TransactionTypeCredit, Amount: 200},
+		{Model: at(16, 10), Type: models.TransactionTypeDebit, Amount: 50},
+		{Model: at(16, 10), Type: models.TransactionTypeFee, Amount: 0.25},
+		{Model: at(17, 23), Type: models.TransactionTypeMoveOut, Amount: 149.75},
+		{Model: at(19, 8), Type: models.TransactionTypeCredit, Amount: 300},
+	}
+	balance := 300.0
+
+	tests := []struct {
+		name string
+		day  time.Time
+		want float64
+	}{
+		{"before the first credit", day(14), 0},
+		{"after the first credit", day(15), 200},
+		{"after the debit and its fee", day(16), 149.75},
+		{"after the move", day(17), 0},
+		{"with nothing written that day", day(18), 0},
+		{"today", day(19), 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, balanceAtEndOf(tt.day, balance, later))
+		})
+	}
+}
+
+func TestBalanceAtEndOfWithIncomingTypes(t *testing.T) {
+	now := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+	later := []models.Transaction{
+		{Model: gorm.Model{CreatedAt: now}, Type: models.TransactionTypeMoveIn, Amount: 10},
+		{Model: gorm.Model{CreatedAt: now}, Type: models.TransactionTypeFxIn, Amount: 20},
+		{Model: gorm.Model{CreatedAt: now}, Type: models.TransactionTypeInterest, Amount: 1.5},
+		{Model: gorm.Model{CreatedAt: now}, Type: models.TransactionTypeFxOut, Amount: 5},
+	}
+
+	require.Equal(t, 73.5, balanceAtEndOf(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), 100, later))
+}
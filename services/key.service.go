@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// signingKey is a JWT key identified by its kid. Keys loaded from a public key file can only verify tokens.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// Global variables holding every key accepted for verification and the key used to sign new tokens
+var signingKeys = map[string]*signingKey{}
+var activeSigningKey *signingKey
+
+// LoadSigningKeys loads the PEM files listed in JWT_KEY_FILES and selects JWT_KEY_ID as the signing key.
+// Without JWT_KEY_ID tokens keep being signed with HS256 and JWT_SECRET.
+func LoadSigningKeys() {
+	keys := map[string]*signingKey{}
+	for _, entry := range Config.JWTKeyFiles {
+		kid, path, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || kid == "" || path == "" {
+			panic(fmt.Errorf("invalid JWT_KEY_FILES entry %q, expected kid=path", entry))
+		}
+
+		key, err := loadSigningKey(kid, path)
+		if err != nil {
+			panic(err)
+		}
+		keys[kid] = key
+	}
+
+	var active *signingKey
+	if Config.JWTKeyID != "" {
+		active = keys[Config.JWTKeyID]
+		if active == nil {
+			panic(fmt.Errorf("JWT_KEY_ID %q is not listed in JWT_KEY_FILES", Config.JWTKeyID))
+		}
+		if active.Private == nil {
+			panic(fmt.Errorf("JWT_KEY_ID %q has no private key", Config.JWTKeyID))
+		}
+	}
+
+	signingKeys = keys
+	activeSigningKey = active
+
+	if active != nil {
+		logger.Info("Signing tokens with asymmetric key", zap.String("kid", active.ID), zap.String("alg", active.Method.Alg()), zap.Int("keys", len(keys)))
+	}
+}
+
+// loadSigningKey reads an RSA or Ed25519 private or public key from a PEM file
+func loadSigningKey(kid string, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key %s: %v", kid, err)
+	}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	}
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: private.(ed25519.PrivateKey).Public()}, nil
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Public: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Public: public}, nil
+	}
+
+	return nil, fmt.Errorf("key %s is not an RSA or Ed25519 PEM key", kid)
+}
+
+// signToken signs the claims with the active key, falling back to HS256 with the shared secret
+func signToken(claims jwt.Claims) (string, error) {
+	if activeSigningKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(Config.JWTSecretKey))
+	}
+
+	token := jwt.NewWithClaims(activeSigningKey.Method, claims)
+	token.Header["kid"] = activeSigningKey.ID
+	return token.SignedString(activeSigningKey.Private)
+}
+
+// verificationKey picks the key for a parsed token by its kid header.
+// Tokens without a kid were signed with the shared secret before key rotation was enabled.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || Config.JWTSecretKey == "" {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return []byte(Config.JWTSecretKey), nil
+	}
+
+	key := signingKeys[kid]
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %s", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public part of every loaded key so other services can verify our tokens
+func JWKS() models.JSONWebKeySet {
+	kids := make([]string, 0, len(signingKeys))
+	for kid := range signingKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, kid := range kids {
+		key := signingKeys[kid]
+		jwk := models.JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
@@ -1,18 +1,24 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"ledger-service/logger"
+	"ledger-service/metrics"
 	"ledger-service/models"
+	"sort"
 	"time"
 )
 
@@ -21,135 +27,528 @@ const (
 	TransactionPageSize = 10
 )
 
-// AddFunds adds funds to a user's account and creates a transaction record.
-// Uses a distributed lock to prevent race conditions between concurrent requests.
-func AddFunds(ctx *gin.Context, uid string, request models.AddFundsRequest) (string, error) {
+// StaleCacheTTL is how long the last known balance and history pages are kept for degraded reads
+const StaleCacheTTL = 24 * time.Hour
+
+// AddFunds adds funds to a wallet of a user and creates a transaction record.
+// When a risk rule holds the credit for review, nothing is written and the hold is returned instead.
+func AddFunds(ctx context.Context, uid string, walletName string, request models.AddFundsRequest) (models.Posting, error) {
+	return postCredit(ctx, uid, walletName, request, uuid.New().String(), false)
+}
+
+// postCredit credits a wallet with the transaction ID. The risk rules are skipped for credits an operator approved.
+// Uses a distributed per-wallet lock to prevent race conditions between concurrent requests.
+func postCredit(ctx context.Context, uid string, walletName string, request models.AddFundsRequest, transactionID string, approved bool) (models.Posting, error) {
 	amount := request.Amount
 	if amount <= 0 {
-		return "", errors.New("Amount must be positive")
+		return models.Posting{}, models.NewError(models.ErrValidation, "Amount must be positive")
+	}
+
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.Posting{}, err
 	}
+	defer done()
+
+	db := dbWithContext(ctx)
 
 	var user models.User
-	// Find or create user with given UID
-	result := DbConnection.FirstOrCreate(&user, models.User{UID: uid})
+	var result *gorm.DB
+	if Config.AutoCreateUsers {
+		// Legacy behaviour: find or create user with given UID
+		result = db.FirstOrCreate(&user, models.User{UID: uid, Status: models.UserStatusActive})
+		if result.Error == nil {
+			result = db.FirstOrCreate(&models.Wallet{}, models.Wallet{UserID: user.ID, Name: models.MainWallet, Currency: models.DefaultCurrency})
+		}
+	} else {
+		result = db.Where("uid = ?", uid).First(&user)
+	}
 	if result.Error != nil {
-		logger.Error("Error finding or creating user: %v\n", zap.Error(result.Error))
-		return "", errors.New("Internal Server Error")
+		err := classifyDBError(result.Error, "User not found")
+		if !errors.Is(err, models.ErrNotFound) {
+			logger.ErrorCtx(ctx, "Error finding user", zap.Error(result.Error))
+		}
+		return models.Posting{}, err
 	}
 
-	// Generate a unique transaction ID
-	transactionID := uuid.New().String()
+	wallet, err := findWallet(db, user, walletName)
+	if err != nil {
+		return models.Posting{}, err
+	}
+
+	logCtx := logger.WithFields(ctx, zap.String("transaction_id", transactionID), zap.String("wallet", walletName))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("ledger.transaction_id", transactionID))
 
 	// Check if the transaction ID already exists
 	var existingTransaction models.Transaction
-	result = DbConnection.Where("transaction_id = ?", transactionID).First(&existingTransaction)
+	result = db.Where("transaction_id = ?", transactionID).First(&existingTransaction)
 	if result.Error != nil && !result.RecordNotFound() {
-		logger.Error("Error checking for existing transaction: %v\n", zap.Error(result.Error))
-		return "", errors.New("Internal Server Error")
+		logger.ErrorCtx(logCtx, "Error checking for existing transaction", zap.Error(result.Error))
+		return models.Posting{}, models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", result.Error)
 	}
 
 	if !result.RecordNotFound() {
-		return "", errors.New("Transaction already processed")
+		return models.Posting{}, models.NewError(models.ErrConflict, "Transaction already processed")
 	}
 
-	// Acquire distributed lock using Redsync
-	redisClient := GetRedisDefaultClient()
-	redsyncPool := goredis.NewPool(redisClient)
-	rs := redsync.New(redsyncPool)
+	// Acquire the per-wallet balance lock and check the account state inside it
+	mutex, err := lockWallet(ctx, logCtx, uid, walletName)
+	if err != nil {
+		return models.Posting{}, err
+	}
+	defer releaseLock(mutex)
 
-	mutex := rs.NewMutex("balance_mutex:" + uid)
-	if err := mutex.Lock(); err != nil {
-		logger.Error("Error acquiring lock: %v\n", zap.Error(err))
-		return "", errors.New("Internal Server Error")
+	// Reload the user and wallet so the state and balance cannot change underneath the lock
+	if err := reloadLocked(db, &user, &wallet); err != nil {
+		logger.ErrorCtx(logCtx, "Error reloading wallet", zap.Error(err))
+		return models.Posting{}, classifyDBError(err, "Wallet not found")
+	}
+	if err := checkCredit(user); err != nil {
+		return models.Posting{}, err
+	}
+	if !approved {
+		input := models.RiskInput{User: user, Wallet: walletName, Type: models.TransactionTypeCredit, Amount: amount, Metadata: request.Metadata}
+		decision, err := evaluateRisk(db, logCtx, input)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		if decision != nil {
+			hold, err := applyRiskDecision(db, input, decision, transactionID)
+			return models.Posting{Hold: hold}, err
+		}
 	}
-	defer mutex.Unlock()
 
-	// Create transaction record and update user's balance
+	releaseVelocity, err := reserveVelocity(ctx, logCtx, user, models.TransactionTypeCredit, amount)
+	if err != nil {
+		return models.Posting{}, err
+	}
+
+	// Create transaction record and update the wallet's balance in one database transaction
 	transaction := models.Transaction{
 		UserID:        user.ID,
+		WalletID:      wallet.ID,
 		Amount:        amount,
-		Type:          "credit",
+		Type:          models.TransactionTypeCredit,
 		TransactionID: transactionID, // Use the generated transaction ID
+		Currency:      wallet.Currency,
 	}
-	result = DbConnection.Create(&transaction)
-	if result.Error != nil {
-		logger.Error("Error creating transaction: %v\n", zap.Error(result.Error))
-		return "", errors.New("Internal Server Error")
+	tx := db.Begin()
+	err = tx.Error
+	if err == nil {
+		err = tx.Create(&transaction).Error
 	}
-
-	user.Balance += amount
-	result = DbConnection.Save(&user)
-	if result.Error != nil {
-		logger.Error("Error updating user balance: %v\n", zap.Error(result.Error))
-		return "", errors.New("Internal Server Error")
+	if err == nil {
+		wallet.Balance += amount
+		err = tx.Model(&wallet).Update("balance", wallet.Balance).Error
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		releaseVelocity()
+		logger.ErrorCtx(logCtx, "Error adding funds", zap.Error(err))
+		return models.Posting{}, models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", err)
 	}
 
+	logger.InfoCtx(logCtx, "Funds added", zap.Float64("amount", amount))
+	metrics.FundsAdded.WithLabelValues(wallet.Currency).Inc()
+	metrics.FundsAddedAmount.WithLabelValues(wallet.Currency).Add(amount)
+
 	// Invalidate the cache for balance and transaction history
-	err := redisClient.Del(ctx, "balance:"+uid).Err()
+	invalidateBalanceCache(ctx, logCtx, uid, walletName)
+
+	return models.Posting{TransactionID: transactionID}, nil
+}
+
+// MoveFunds moves funds between two wallets of the same user, with the move fee charged to the source wallet.
+// When a risk rule holds the move for review, nothing is written and the hold is returned instead.
+func MoveFunds(ctx context.Context, uid string, fromWallet string, request models.MoveFundsRequest) (models.Posting, error) {
+	return postMove(ctx, uid, fromWallet, request, uuid.New().String(), false)
+}
+
+// postMove moves funds between two wallets with the move ID. The risk rules are skipped for moves an operator approved.
+// Both wallet locks are held while the debit and the credit are recorded in one database transaction.
+func postMove(ctx context.Context, uid string, fromWallet string, request models.MoveFundsRequest, moveID string, approved bool) (models.Posting, error) {
+	amount := request.Amount
+	if amount <= 0 {
+		return models.Posting{}, models.NewError(models.ErrValidation, "Amount must be positive")
+	}
+	if fromWallet == request.ToWallet {
+		return models.Posting{}, models.NewError(models.ErrValidation, "Funds can only be moved to another wallet")
+	}
+	if err := checkTransactionAmount(amount); err != nil {
+		return models.Posting{}, err
+	}
+
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.Posting{}, err
+	}
+	defer done()
+
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.Where("uid = ?", uid).First(&user).Error; err != nil {
+		return models.Posting{}, classifyDBError(err, "User not found")
+	}
+	from, err := findWallet(db, user, fromWallet)
+	if err != nil {
+		return models.Posting{}, err
+	}
+	to, err := findWallet(db, user, request.ToWallet)
+	if err != nil {
+		return models.Posting{}, err
+	}
+	if from.Currency != to.Currency {
+		return models.Posting{}, models.NewError(models.ErrValidation, "Wallets are in different currencies, convert the funds instead")
+	}
+
+	logCtx := logger.WithFields(ctx, zap.String("transaction_id", moveID), zap.String("wallet", fromWallet), zap.String("to_wallet", request.ToWallet))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("ledger.transaction_id", moveID))
+
+	mutexes, err := lockWallets(ctx, logCtx, uid, []string{fromWallet, request.ToWallet})
+	if err != nil {
+		return models.Posting{}, err
+	}
+	defer releaseLocks(mutexes)
+
+	if err := reloadLocked(db, &user, &from, &to); err != nil {
+		logger.ErrorCtx(logCtx, "Error reloading wallets", zap.Error(err))
+		return models.Posting{}, classifyDBError(err, "Wallet not found")
+	}
+	if err := checkDebit(user); err != nil {
+		return models.Posting{}, err
+	}
+	// The fee is charged to the source wallet on top of the amount moved
+	var fee *models.Fee
+	required := amount
+	if chargesFee(uid) {
+		fee = calculateFee(models.FeeTypeMove, amount)
+	}
+	if fee != nil {
+		required += fee.Amount
+	}
+	if availableFunds(user, from) < required {
+		return models.Posting{}, models.NewError(models.ErrInsufficientFunds, "Insufficient funds in the wallet")
+	}
+	if !approved {
+		input := models.RiskInput{User: user, Wallet: fromWallet, ToWallet: request.ToWallet, Type: models.TransactionTypeMoveOut, Amount: amount, Metadata: request.Metadata}
+		decision, err := evaluateRisk(db, logCtx, input)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		if decision != nil {
+			hold, err := applyRiskDecision(db, input, decision, moveID)
+			return models.Posting{Hold: hold}, err
+		}
+	}
+
+	var feeWallet models.Wallet
+	if fee != nil {
+		var feeMutex *redsync.Mutex
+		feeMutex, feeWallet, err = lockFeeAccount(ctx, logCtx, db, from.Currency)
+		if err != nil {
+			return models.Posting{}, err
+		}
+		defer releaseLock(feeMutex)
+	}
+
+	tx := db.Begin()
+	err = tx.Error
+	if err == nil {
+		err = postTransfer(tx, &from, &to, amount, moveID, models.TransactionTypeMoveOut, models.TransactionTypeMoveIn)
+	}
+	if err == nil && fee != nil {
+		err = postFee(tx, &from, &feeWallet, fee, moveID)
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
 	if err != nil {
-		logger.Error("Error deleting balance cache: %v\n", zap.Error(err))
+		logger.ErrorCtx(logCtx, "Error moving funds", zap.Error(err))
+		return models.Posting{}, classifyDBError(err, "Wallet not found")
 	}
 
-	// Invalidate the cache for all pages of transaction history
+	logger.InfoCtx(logCtx, "Funds moved", zap.Float64("amount", amount))
+	invalidateBalanceCache(ctx, logCtx, uid, fromWallet)
+	invalidateBalanceCache(ctx, logCtx, uid, request.ToWallet)
+	if fee != nil {
+		chargedFee(ctx, logCtx, models.FeeTypeMove, fee, feeWallet)
+	}
+
+	return models.Posting{TransactionID: moveID, Fee: fee}, nil
+}
+
+// postTransfer records a debit on the source wallet and a credit on the destination wallet and updates both balances.
+// Both wallets have to be in the same currency.
+// The transactions get the transfer ID suffixed with their side, so both legs can be found together.
+func postTransfer(tx *gorm.DB, from *models.Wallet, to *models.Wallet, amount float64, transferID string, debitType string, creditType string) error {
+	transactions := []models.Transaction{
+		{UserID: from.UserID, WalletID: from.ID, Amount: amount, Type: debitType, TransactionID: transferID + ":debit", Currency: from.Currency},
+		{UserID: to.UserID, WalletID: to.ID, Amount: amount, Type: creditType, TransactionID: transferID + ":credit", Currency: to.Currency},
+	}
+	for i := range transactions {
+		if err := tx.Create(&transactions[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	from.Balance -= amount
+	to.Balance += amount
+	if err := tx.Model(from).Update("balance", from.Balance).Error; err != nil {
+		return err
+	}
+	return tx.Model(to).Update("balance", to.Balance).Error
+}
+
+// findWallet returns the wallet of the user with the name
+func findWallet(db *gorm.DB, user models.User, name string) (models.Wallet, error) {
+	var wallet models.Wallet
+	if err := db.Where("user_id = ? AND name = ?", user.ID, name).First(&wallet).Error; err != nil {
+		return models.Wallet{}, classifyDBError(err, "Wallet not found")
+	}
+	return wallet, nil
+}
+
+// findCurrencyWallet returns the wallet of an account in the currency, its main wallet first.
+// Funds received by house accounts, such as fees and swept balances, go to the wallet of their currency.
+func findCurrencyWallet(db *gorm.DB, user models.User, currency string) (models.Wallet, error) {
+	var wallet models.Wallet
+	err := orderWallets(db.Where("user_id = ? AND currency = ?", user.ID, currency)).First(&wallet).Error
+	if err != nil {
+		return models.Wallet{}, classifyDBError(err, "Account "+user.UID+" has no "+currency+" wallet")
+	}
+	return wallet, nil
+}
+
+// reloadLocked reloads the user and wallets after their locks were acquired
+func reloadLocked(db *gorm.DB, user *models.User, wallets ...*models.Wallet) error {
+	if err := db.First(user, user.ID).Error; err != nil {
+		return err
+	}
+	for _, wallet := range wallets {
+		if err := db.First(wallet, wallet.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCredit rejects credits to closed accounts and to frozen accounts whose credits are blocked
+func checkCredit(user models.User) error {
+	if user.Status == models.UserStatusClosed {
+		return models.NewError(models.ErrAccountClosed, "Account is closed")
+	}
+	if user.Status == models.UserStatusFrozen && user.CreditsBlocked {
+		return models.NewError(models.ErrAccountFrozen, "Account is frozen")
+	}
+	return nil
+}
+
+// checkDebit rejects debits from closed and frozen accounts
+func checkDebit(user models.User) error {
+	if user.Status == models.UserStatusClosed {
+		return models.NewError(models.ErrAccountClosed, "Account is closed")
+	}
+	if user.Status == models.UserStatusFrozen {
+		return models.NewError(models.ErrAccountFrozen, "Account is frozen")
+	}
+	return nil
+}
+
+// walletKey names a wallet of a UID in cache keys and lock names
+func walletKey(uid string, wallet string) string {
+	return uid + ":" + wallet
+}
+
+// invalidateBalanceCache deletes the cached balance and every cached page of transaction history of the wallet.
+// The keys are deleted with a single command, so a write costs one round trip to Redis.
+func invalidateBalanceCache(ctx context.Context, logCtx context.Context, uid string, wallet string) {
+	keys := make([]string, 0, MaxPages+1)
+	keys = append(keys, "balance:"+walletKey(uid, wallet))
 	for i := 1; i <= MaxPages; i++ {
-		cacheKey := fmt.Sprintf("transactions:%s:%d:%d", uid, i, TransactionPageSize)
-		err = redisClient.Del(ctx, cacheKey).Err()
+		keys = append(keys, fmt.Sprintf("transactions:%s:%d:%d", walletKey(uid, wallet), i, TransactionPageSize))
+	}
+
+	if err := GetRedisDefaultClient().Del(ctx, keys...).Err(); err != nil {
+		logger.ErrorCtx(logCtx, "Error deleting balance cache", zap.Error(err))
+	}
+}
+
+// lockWallet acquires the distributed per-wallet balance lock using Redsync.
+// Every change to a wallet's balance has to hold it; release it with releaseLock.
+// Writes that hold the lock longer than a single posting pass a longer expiry in the options.
+func lockWallet(ctx context.Context, logCtx context.Context, uid string, wallet string, options ...redsync.Option) (*redsync.Mutex, error) {
+	return acquireLock(ctx, logCtx, "balance_mutex:"+walletKey(uid, wallet), "balance", "Balance is locked by another request, try again", options...)
+}
+
+// lockUser acquires the distributed per-account lock. Changes to the set of wallets of an account hold it,
+// so a state change that locks every wallet cannot miss a wallet added meanwhile. It is taken before any wallet lock.
+func lockUser(ctx context.Context, logCtx context.Context, uid string) (*redsync.Mutex, error) {
+	return acquireLock(ctx, logCtx, "user_mutex:"+uid, "user", "Account is locked by another request, try again")
+}
+
+// acquireLock acquires a distributed lock using Redsync, recording the wait and failures under the lock kind
+func acquireLock(ctx context.Context, logCtx context.Context, name string, kind string, lockedMessage string, options ...redsync.Option) (*redsync.Mutex, error) {
+	redsyncPool := goredis.NewPool(GetRedisDefaultClient())
+	rs := redsync.New(redsyncPool)
+
+	mutex := rs.NewMutex(name, options...)
+	lockCtx, lockSpan := Tracer().Start(ctx, "redsync lock", trace.WithAttributes(attribute.String("lock.name", mutex.Name())))
+	lockStart := time.Now()
+	if err := mutex.LockContext(lockCtx); err != nil {
+		metrics.LockFailures.WithLabelValues(kind).Inc()
+		lockSpan.RecordError(err)
+		lockSpan.SetStatus(codes.Error, err.Error())
+		lockSpan.End()
+		logger.ErrorCtx(logCtx, "Error acquiring lock", zap.Error(err))
+		if errors.Is(err, redsync.ErrFailed) {
+			return nil, models.WrapError(models.ErrLockTimeout, lockedMessage, err)
+		}
+		return nil, models.WrapError(models.ErrDependencyUnavailable, "Lock service is unavailable", err)
+	}
+	metrics.LockWaitDuration.WithLabelValues(kind).Observe(time.Since(lockStart).Seconds())
+	lockSpan.End()
+	return mutex, nil
+}
+
+// lockWallets acquires the locks of several wallets of a UID in name order, so concurrent requests cannot deadlock.
+// Locks that were acquired are released again when one of them cannot be acquired.
+func lockWallets(ctx context.Context, logCtx context.Context, uid string, wallets []string) ([]*redsync.Mutex, error) {
+	names := append([]string(nil), wallets...)
+	sort.Strings(names)
+
+	mutexes := make([]*redsync.Mutex, 0, len(names))
+	for _, name := range names {
+		mutex, err := lockWallet(ctx, logCtx, uid, name)
 		if err != nil {
-			logger.Error("Error deleting transaction history cache: %v\n", zap.Error(err))
+			releaseLocks(mutexes)
+			return nil, err
 		}
+		mutexes = append(mutexes, mutex)
 	}
+	return mutexes, nil
+}
 
-	return "Funds added successfully", nil
+// releaseLocks unlocks every lock taken with lockWallets
+func releaseLocks(mutexes []*redsync.Mutex) {
+	for _, mutex := range mutexes {
+		releaseLock(mutex)
+	}
 }
 
-// GetBalance retrieves the balance for the specified UID.
-// Uses Redis cache to speed up subsequent requests.
-func GetBalance(ctx *gin.Context, uid string) (float64, error) {
-	var user models.User
+// GetBalance retrieves the balance of a wallet of the specified UID.
+// Uses Redis cache to speed up subsequent requests. When the database is unavailable the last known
+// balance is served from the stale cache and reported as degraded.
+func GetBalance(ctx context.Context, uid string, walletName string) (float64, bool, error) {
+	db := dbWithContext(ctx)
 	redisClient := GetRedisDefaultClient()
+	key := walletKey(uid, walletName)
 
 	// Check the cache first
-	cachedBalance, err := redisClient.Get(ctx, "balance:"+uid).Result()
+	cachedBalance, err := redisClient.Get(ctx, "balance:"+key).Result()
 
 	if err == nil {
 		// If the balance is found in the cache, return it
-		return cast.ToFloat64(cachedBalance), nil
+		metrics.CacheRequests.WithLabelValues("balance", metrics.CacheHit).Inc()
+		return cast.ToFloat64(cachedBalance), false, nil
 	} else if err != redis.Nil {
 		// If there was an error retrieving the balance from the cache, log it
-		logger.Error("Error getting balance from Redis cache: %v\n", zap.Error(err))
+		metrics.CacheRequests.WithLabelValues("balance", metrics.CacheError).Inc()
+		logger.ErrorCtx(ctx, "Error getting balance from Redis cache", zap.Error(err))
+	} else {
+		metrics.CacheRequests.WithLabelValues("balance", metrics.CacheMiss).Inc()
 	}
 
 	// If the balance is not in the cache, fetch it from the database
-	result := DbConnection.Where("uid = ?", uid).First(&user)
-	if result.Error != nil {
-		// If the user is not found in the database, return an error
-		return 0, errors.New("User not found")
+	var user models.User
+	err = db.Where("uid = ?", uid).First(&user).Error
+	if err != nil {
+		err = classifyDBError(err, "User not found")
+	}
+	var wallet models.Wallet
+	if err == nil {
+		wallet, err = findWallet(db, user, walletName)
+	}
+	if err != nil {
+		if !errors.Is(err, models.ErrDependencyUnavailable) {
+			return 0, false, err
+		}
+
+		// Serve the last known balance while the database is unavailable
+		logger.ErrorCtx(ctx, "Error fetching balance from the database", zap.Error(err))
+		staleBalance, staleErr := redisClient.Get(ctx, "balance_stale:"+key).Result()
+		if staleErr != nil {
+			return 0, false, err
+		}
+		return cast.ToFloat64(staleBalance), true, nil
 	}
 
-	// Update the cache with the new balance
-	err = redisClient.Set(ctx, "balance:"+uid, user.Balance, time.Minute).Err()
+	// Update the cache with the new balance, and keep a stale copy for degraded reads
+	_, err = redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "balance:"+key, wallet.Balance, time.Minute)
+		pipe.Set(ctx, "balance_stale:"+key, wallet.Balance, StaleCacheTTL)
+		return nil
+	})
 	if err != nil {
 		// If there was an error updating the cache, log it
-		logger.Error("Error setting balance in Redis cache: %v\n", zap.Error(err))
+		logger.ErrorCtx(ctx, "Error setting balance in Redis cache", zap.Error(err))
 	}
 
 	// Return the balance
-	return user.Balance, nil
+	return wallet.Balance, false, nil
+}
+
+// GetTransactionHistory retrieves the transaction history of a wallet of the specified UID with pagination.
+// When the database is unavailable the last known page is served from the stale cache and reported as degraded.
+func GetTransactionHistory(ctx context.Context, uid string, walletName string, page int, limit int) (map[string]interface{}, error) {
+	redisClient := GetRedisDefaultClient()
+
+	history, err := getTransactionHistory(ctx, uid, walletName, page, limit)
+	if err == nil || !errors.Is(err, models.ErrDependencyUnavailable) {
+		return history, err
+	}
+
+	// Serve the last known page while the database is unavailable
+	logger.ErrorCtx(ctx, "Error fetching transaction history from the database", zap.Error(err))
+	staleKey := fmt.Sprintf("transactions_stale:%s:%d:%d", walletKey(uid, walletName), page, limit)
+	cachedHistory, staleErr := redisClient.Get(ctx, staleKey).Result()
+	if staleErr != nil {
+		return nil, err
+	}
+
+	var staleHistory map[string]interface{}
+	if staleErr = json.Unmarshal([]byte(cachedHistory), &staleHistory); staleErr != nil {
+		logger.ErrorCtx(ctx, "Error unmarshalling transaction history from Redis cache", zap.Error(staleErr))
+		return nil, err
+	}
+	staleHistory["degraded"] = true
+	return staleHistory, nil
 }
 
-// GetTransactionHistory retrieves the transaction history for the specified UID with pagination.
-func GetTransactionHistory(ctx *gin.Context, uid string, page int, limit int) (map[string]interface{}, error) {
+// getTransactionHistory reads a page of the transaction history of a wallet from the cache or the database
+func getTransactionHistory(ctx context.Context, uid string, walletName string, page int, limit int) (map[string]interface{}, error) {
+	db := dbWithContext(ctx)
 	// Calculate the offset
 	offset := (page - 1) * limit
-	// Find the user with the given UID
+	// Find the user with the given UID and its wallet
 	var user models.User
-	result := DbConnection.Where("uid = ?", uid).First(&user)
+	result := db.Where("uid = ?", uid).First(&user)
 	if result.Error != nil {
-		return nil, errors.New("User not found")
+		return nil, classifyDBError(result.Error, "User not found")
+	}
+	wallet, err := findWallet(db, user, walletName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build the Redis cache key
-	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", uid, page, limit)
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", walletKey(uid, walletName), page, limit)
 	redisClient := GetRedisDefaultClient()
 
 	// Check if the transaction history is already in cache
@@ -157,7 +556,12 @@ func GetTransactionHistory(ctx *gin.Context, uid string, page int, limit int) (m
 
 	// If there is an error other than "key not found", log it
 	if err != nil && err != redis.Nil {
-		logger.Error("Error getting transactions from Redis cache: %v\n", zap.Error(err))
+		metrics.CacheRequests.WithLabelValues("transactions", metrics.CacheError).Inc()
+		logger.ErrorCtx(ctx, "Error getting transactions from Redis cache", zap.Error(err))
+	} else if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("transactions", metrics.CacheMiss).Inc()
+	} else {
+		metrics.CacheRequests.WithLabelValues("transactions", metrics.CacheHit).Inc()
 	}
 
 	var transactions []models.Transaction
@@ -165,15 +569,15 @@ func GetTransactionHistory(ctx *gin.Context, uid string, page int, limit int) (m
 		// If the transaction history is in cache, unmarshal the JSON data
 		err = json.Unmarshal([]byte(cachedTransactions), &transactions)
 		if err != nil {
-			logger.Error("Error unmarshalling transactions from Redis cache: %v\n", zap.Error(err))
+			logger.ErrorCtx(ctx, "Error unmarshalling transactions from Redis cache", zap.Error(err))
 		}
 	}
 
 	if len(transactions) == 0 {
 		// If the transaction history is not in cache, fetch it from the database
-		result = DbConnection.Where("user_id = ?", user.ID).Limit(limit).Offset(offset).Find(&transactions)
+		result = db.Where("wallet_id = ?", wallet.ID).Limit(limit).Offset(offset).Find(&transactions)
 		if result.Error != nil {
-			return nil, errors.New("Error fetching transactions from the database")
+			return nil, classifyDBError(result.Error, "Transactions not found")
 		}
 
 		// Store the transaction history in cache for 10 minutes
@@ -181,16 +585,18 @@ func GetTransactionHistory(ctx *gin.Context, uid string, page int, limit int) (m
 		if err == nil {
 			err = redisClient.Set(ctx, cacheKey, cacheData, 10*time.Minute).Err()
 			if err != nil {
-				logger.Error("Error setting transactions in Redis cache: %v\n", zap.Error(err))
+				logger.ErrorCtx(ctx, "Error setting transactions in Redis cache", zap.Error(err))
 			}
 		} else {
-			logger.Error("Error marshalling transactions for Redis cache: %v\n", zap.Error(err))
+			logger.ErrorCtx(ctx, "Error marshalling transactions for Redis cache", zap.Error(err))
 		}
 	}
 
-	// Calculate the total number of transactions for the user
+	// Calculate the total number of transactions of the wallet
 	var count int64
-	DbConnection.Model(&models.Transaction{}).Where("user_id = ?", user.ID).Count(&count)
+	if err := db.Model(&models.Transaction{}).Where("wallet_id = ?", wallet.ID).Count(&count).Error; err != nil {
+		return nil, classifyDBError(err, "Transactions not found")
+	}
 
 	// Calculate total pages and the next page (if any)
 	totalPages := int(count) / limit
@@ -203,12 +609,23 @@ func GetTransactionHistory(ctx *gin.Context, uid string, page int, limit int) (m
 		nextPageID = page + 1
 	}
 
-	return map[string]interface{}{
+	history := map[string]interface{}{
 		"transactions": transactions,
 		"pagination": map[string]interface{}{
 			"current_page": page,
 			"total_pages":  totalPages,
 			"next_page_id": nextPageID,
 		},
-	}, nil
+	}
+
+	// Keep a stale copy of the page for degraded reads
+	staleData, err := json.Marshal(history)
+	if err == nil {
+		err = redisClient.Set(ctx, fmt.Sprintf("transactions_stale:%s:%d:%d", walletKey(uid, walletName), page, limit), staleData, StaleCacheTTL).Err()
+	}
+	if err != nil {
+		logger.ErrorCtx(ctx, "Error setting stale transaction history in Redis cache", zap.Error(err))
+	}
+
+	return history, nil
 }
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+)
+
+// GetLimits returns the limits of the account of the UID and the funds they leave available in its main wallet
+func GetLimits(ctx context.Context, uid string) (models.Limits, error) {
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return models.Limits{}, err
+	}
+	return limitsOf(user), nil
+}
+
+// SetLimits changes the limits of the account of the UID and records every change in the audit table.
+// The main wallet lock is held so no debit is checked against the old limit while it changes.
+func SetLimits(ctx context.Context, uid string, request models.SetLimitsRequest, actor string) (models.Limits, error) {
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.Limits{}, err
+	}
+	defer done()
+
+	db := dbWithContext(ctx)
+	logCtx := logger.WithFields(ctx, zap.String("actor", actor))
+
+	mutex, err := lockWallet(ctx, logCtx, uid, models.MainWallet)
+	if err != nil {
+		return models.Limits{}, err
+	}
+	defer releaseLock(mutex)
+
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return models.Limits{}, err
+	}
+	if user.Status == models.UserStatusClosed {
+		return models.Limits{}, models.NewError(models.ErrAccountClosed, "Account is closed")
+	}
+	if *request.OverdraftLimit == user.OverdraftLimit {
+		return limitsOf(user), nil
+	}
+
+	change := models.LimitChange{
+		UserID:   user.ID,
+		UID:      user.UID,
+		Limit:    models.LimitOverdraft,
+		OldValue: user.OverdraftLimit,
+		NewValue: *request.OverdraftLimit,
+		Note:     request.Note,
+		Actor:    actor,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:save_associations", false).Model(&user).Update("overdraft_limit", change.NewValue).Error; err != nil {
+			return err
+		}
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		logger.ErrorCtx(logCtx, "Error changing limits", zap.Error(err))
+		return models.Limits{}, classifyDBError(err, "User not found")
+	}
+
+	logger.InfoCtx(logCtx, "Limit changed", zap.String("limit", change.Limit), zap.Float64("old_value", change.OldValue), zap.Float64("new_value", change.NewValue))
+	return limitsOf(user), nil
+}
+
+// ListLimitChanges returns the audit trail of limit changes of the account of the UID, newest first
+func ListLimitChanges(ctx context.Context, uid string) ([]models.LimitChange, error) {
+	db := dbWithContext(ctx)
+
+	if _, err := GetUser(ctx, uid); err != nil {
+		return nil, err
+	}
+
+	var changes []models.LimitChange
+	if err := db.Where("uid = ?", uid).Order("created_at desc").Find(&changes).Error; err != nil {
+		return nil, classifyDBError(err, "Limit changes not found")
+	}
+	return changes, nil
+}
+
+// availableFunds returns how much can be debited from the wallet. Only the main wallet may be overdrawn.
+func availableFunds(user models.User, wallet models.Wallet) float64 {
+	if wallet.Name == models.MainWallet {
+		return wallet.Balance + user.OverdraftLimit
+	}
+	return wallet.Balance
+}
+
+// limitsOf returns the limits of the user loaded with its wallets
+func limitsOf(user models.User) models.Limits {
+	limits := models.Limits{UID: user.UID, OverdraftLimit: user.OverdraftLimit}
+	for _, wallet := range user.Wallets {
+		if wallet.Name == models.MainWallet {
+			limits.Balance = wallet.Balance
+			limits.Available = availableFunds(user, wallet)
+		}
+	}
+	return limits
+}
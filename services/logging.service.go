@@ -0,0 +1,51 @@
+package services
+
+import (
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"ledger-service/logger"
+	"os"
+)
+
+// Values of LOG_OUTPUT that do not name a file
+const (
+	LogOutputStdout = "stdout"
+	LogOutputStderr = "stderr"
+)
+
+// ConfigureLogging applies LOG_LEVEL, LOG_FORMAT and LOG_OUTPUT to the application logger.
+// The access log is written to ACCESS_LOG_FILE and mirrored to stdout.
+func ConfigureLogging() {
+	var output zapcore.WriteSyncer
+	switch Config.LogOutput {
+	case LogOutputStdout:
+		output = zapcore.Lock(os.Stdout)
+	case LogOutputStderr:
+		output = zapcore.Lock(os.Stderr)
+	default:
+		output = zapcore.AddSync(RotatingWriter(Config.LogOutput))
+	}
+
+	if err := logger.Configure(Config.LogLevel, Config.LogFormat, output); err != nil {
+		panic(err)
+	}
+
+	accessOutput := zapcore.NewMultiWriteSyncer(zapcore.AddSync(RotatingWriter(Config.AccessLogFile)), zapcore.Lock(os.Stdout))
+	if err := logger.ConfigureAccess(Config.LogFormat, accessOutput); err != nil {
+		panic(err)
+	}
+}
+
+// RotatingWriter returns a writer to the file that is rotated by LOG_MAX_SIZE_MB
+// and keeps LOG_MAX_BACKUPS rotated files for at most LOG_MAX_AGE_DAYS
+func RotatingWriter(path string) io.Writer {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    Config.LogMaxSizeMB,
+		MaxAge:     Config.LogMaxAgeDays,
+		MaxBackups: Config.LogMaxBackups,
+		Compress:   Config.LogCompress,
+		LocalTime:  true,
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"time"
+)
+
+// recurringTransferBatchSize is how many due transfers one scheduler run executes at most
+const recurringTransferBatchSize = 100
+
+// CreateRecurringTransfer schedules a transfer between two wallets of the user at a fixed interval.
+// The first transfer runs at the start time, or right away without one.
+func CreateRecurringTransfer(ctx context.Context, uid string, fromWallet string, request models.CreateRecurringTransferRequest) (models.RecurringTransfer, error) {
+	if request.Amount <= 0 {
+		return models.RecurringTransfer{}, models.NewError(models.ErrValidation, "Amount must be positive")
+	}
+	if fromWallet == request.ToWallet {
+		return models.RecurringTransfer{}, models.NewError(models.ErrValidation, "Funds can only be moved to another wallet")
+	}
+	if err := checkTransactionAmount(request.Amount); err != nil {
+		return models.RecurringTransfer{}, err
+	}
+
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.Where("uid = ?", uid).First(&user).Error; err != nil {
+		return models.RecurringTransfer{}, classifyDBError(err, "User not found")
+	}
+	if err := checkDebit(user); err != nil {
+		return models.RecurringTransfer{}, err
+	}
+	from, err := findWallet(db, user, fromWallet)
+	if err != nil {
+		return models.RecurringTransfer{}, err
+	}
+	to, err := findWallet(db, user, request.ToWallet)
+	if err != nil {
+		return models.RecurringTransfer{}, err
+	}
+	if from.Currency != to.Currency {
+		return models.RecurringTransfer{}, models.NewError(models.ErrValidation, "Wallets are in different currencies")
+	}
+
+	transfer := models.RecurringTransfer{
+		UserID:     user.ID,
+		UID:        user.UID,
+		FromWallet: fromWallet,
+		ToWallet:   request.ToWallet,
+		Amount:     request.Amount,
+		Interval:   request.Interval,
+		NextRunAt:  time.Now().UTC(),
+	}
+	if request.StartAt != nil && request.StartAt.After(transfer.NextRunAt) {
+		transfer.NextRunAt = request.StartAt.UTC()
+	}
+	transfer.DayOfMonth = transfer.NextRunAt.Day()
+	if err := db.Create(&transfer).Error; err != nil {
+		return models.RecurringTransfer{}, classifyDBError(err, "User not found")
+	}
+
+	logger.InfoCtx(ctx, "Recurring transfer created", zap.Uint("recurring_transfer_id", transfer.ID), zap.String("interval", transfer.Interval))
+	return transfer, nil
+}
+
+// ListRecurringTransfers returns the recurring transfers of the user, in the order they were created
+func ListRecurringTransfers(ctx context.Context, uid string) ([]models.RecurringTransfer, error) {
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.Where("uid = ?", uid).First(&user).Error; err != nil {
+		return nil, classifyDBError(err, "User not found")
+	}
+
+	var transfers []models.RecurringTransfer
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&transfers).Error; err != nil {
+		return nil, classifyDBError(err, "Recurring transfers not found")
+	}
+	return transfers, nil
+}
+
+// DeleteRecurringTransfer cancels a recurring transfer of the user. Transfers already made are kept.
+func DeleteRecurringTransfer(ctx context.Context, uid string, id uint) error {
+	result := dbWithContext(ctx).Where("id = ? AND uid = ?", id, uid).Delete(&models.RecurringTransfer{})
+	if result.Error != nil {
+		return classifyDBError(result.Error, "Recurring transfer not found")
+	}
+	if result.RowsAffected == 0 {
+		return models.NewError(models.ErrNotFound, "Recurring transfer not found")
+	}
+
+	logger.InfoCtx(ctx, "Recurring transfer deleted", zap.Uint("recurring_transfer_id", id))
+	return nil
+}
+
+// runRecurringTransfers makes the transfers that are due. Each run of a transfer is moved with an ID derived
+// from its schedule, so a run that was interrupted after the move is not moved again.
+func runRecurringTransfers(ctx context.Context, now time.Time) error {
+	db := dbWithContext(ctx)
+
+	var transfers []models.RecurringTransfer
+	err := db.Where("next_run_at <= ?", now).Order("next_run_at").Limit(recurringTransferBatchSize).Find(&transfers).Error
+	if err != nil {
+		return classifyDBError(err, "Recurring transfers not found")
+	}
+
+	for _, transfer := range transfers {
+		if err := runRecurringTransfer(ctx, transfer, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRecurringTransfer makes one due transfer and schedules the next run. A transfer that is rejected, for example
+// for insufficient funds, is skipped until its next run; one that failed on an unavailable dependency is retried.
+func runRecurringTransfer(ctx context.Context, transfer models.RecurringTransfer, now time.Time) error {
+	db := dbWithContext(ctx)
+	moveID := fmt.Sprintf("recurring:%d:%d", transfer.ID, transfer.NextRunAt.Unix())
+	logCtx := logger.WithFields(ctx, zap.Uint("recurring_transfer_id", transfer.ID), zap.String("transaction_id", moveID))
+
+	var moved int
+	err := db.Model(&models.Transaction{}).Where("transaction_id = ?", moveID+":debit").Count(&moved).Error
+	if err == nil && moved == 0 {
+		err = db.Model(&models.RiskHold{}).Where("transaction_id = ?", moveID).Count(&moved).Error
+	}
+	if err != nil {
+		return classifyDBError(err, "Transactions not found")
+	}
+
+	lastError := ""
+	if moved == 0 {
+		request := models.MoveFundsRequest{ToWallet: transfer.ToWallet, Amount: transfer.Amount}
+		_, err = postMove(ctx, transfer.UID, transfer.FromWallet, request, moveID, false)
+		switch {
+		case errors.Is(err, models.ErrDependencyUnavailable) || errors.Is(err, models.ErrLockTimeout):
+			return err
+		case errors.Is(err, models.ErrAccountClosed) || errors.Is(err, models.ErrNotFound):
+			// The account was closed or the wallet is gone, so the transfer can never run again
+			logger.InfoCtx(logCtx, "Recurring transfer cancelled", zap.Error(err))
+			return db.Delete(&transfer).Error
+		case err != nil:
+			logger.InfoCtx(logCtx, "Recurring transfer skipped", zap.Error(err))
+			lastError = err.Error()
+		}
+	}
+
+	// Runs missed while no scheduler was running are skipped rather than made all at once
+	next := transfer.NextRunAt
+	for !next.After(now) {
+		next = nextRun(next, transfer.Interval, transfer.DayOfMonth)
+	}
+	ranAt := now
+	return db.Model(&transfer).Updates(map[string]interface{}{
+		"next_run_at": next,
+		"last_run_at": &ranAt,
+		"last_error":  lastError,
+	}).Error
+}
+
+// nextRun returns the run after the given one for the interval. Monthly runs are made on the day of the month
+// the transfer was scheduled for, or on the last day of months without that day.
+// Transfers scheduled before the day was stored keep the day of the given run.
+func nextRun(run time.Time, interval string, dayOfMonth int) time.Time {
+	switch interval {
+	case models.IntervalDaily:
+		return run.AddDate(0, 0, 1)
+	case models.IntervalWeekly:
+		return run.AddDate(0, 0, 7)
+	default:
+		if dayOfMonth == 0 {
+			dayOfMonth = run.Day()
+		}
+		// Day 0 of the month after next is the last day of the next month
+		lastDay := time.Date(run.Year(), run.Month()+2, 0, 0, 0, 0, 0, run.Location()).Day()
+		if dayOfMonth > lastDay {
+			dayOfMonth = lastDay
+		}
+		return time.Date(run.Year(), run.Month()+1, dayOfMonth, run.Hour(), run.Minute(), run.Second(), run.Nanosecond(), run.Location())
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 8, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		run        time.Time
+		interval   string
+		dayOfMonth int
+		want       time.Time
+	}{
+		{"daily over a month end", date(2026, 10, 31), models.IntervalDaily, 31, date(2026, 11, 1)},
+		{"weekly over a year end", date(2026, 12, 28), models.IntervalWeekly, 28, date(2027, 1, 4)},
+		{"monthly", date(2026, 10, 15), models.IntervalMonthly, 15, date(2026, 11, 15)},
+		{"monthly over a year end", date(2026, 12, 31), models.IntervalMonthly, 31, date(2027, 1, 31)},
+		{"monthly into a shorter month", date(2026, 10, 31), models.IntervalMonthly, 31, date(2026, 11, 30)},
+		{"monthly into February", date(2027, 1, 31), models.IntervalMonthly, 31, date(2027, 2, 28)},
+		{"monthly into February of a leap year", date(2028, 1, 30), models.IntervalMonthly, 30, date(2028, 2, 29)},
+		{"monthly back to the scheduled day after February", date(2027, 2, 28), models.IntervalMonthly, 31, date(2027, 3, 31)},
+		{"monthly back to the scheduled day after a shorter month", date(2026, 11, 30), models.IntervalMonthly, 31, date(2026, 12, 31)},
+		{"monthly on the last day of February", date(2027, 2, 28), models.IntervalMonthly, 28, date(2027, 3, 28)},
+		{"monthly without a stored day", date(2026, 10, 20), models.IntervalMonthly, 0, date(2026, 11, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, nextRun(tt.run, tt.interval, tt.dayOfMonth))
+		})
+	}
+}
+
+func TestNextRunKeepsTheScheduledDay(t *testing.T) {
+	run := time.Date(2027, 1, 31, 8, 30, 0, 0, time.UTC)
+	var days []int
+	for i := 0; i < 4; i++ {
+		run = nextRun(run, models.IntervalMonthly, 31)
+		days = append(days, run.Day())
+	}
+	require.Equal(t, []int{28, 31, 30, 31}, days)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisTracingHook creates a span for every Redis command and pipeline run within a traced request.
+// Commands without a parent span, like health checks and lock releases on shutdown, are not traced.
+type redisTracingHook struct{}
+
+func (redisTracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, _ = Tracer().Start(ctx, "redis "+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation(cmd.Name())),
+	)
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (redisTracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, _ = Tracer().Start(ctx, "redis pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis),
+	)
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+// endRedisSpan ends the span started by the hook. A missing key is a normal cache miss, not an error.
+func endRedisSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
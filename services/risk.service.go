@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"ledger-service/logger"
+	"ledger-service/metrics"
+	"ledger-service/models"
+	"os"
+	"time"
+)
+
+// RiskRule is a check evaluated before a credit, debit or move between wallets is written.
+// Evaluate returns nil to allow the transaction, or a decision to deny it or hold it for review.
+type RiskRule interface {
+	Evaluate(input models.RiskInput) *models.RiskDecision
+}
+
+// RiskRuleFactory builds a rule from its entry in RISK_RULES_FILE
+type RiskRuleFactory func(name string, action string, config *yaml.Node) (RiskRule, error)
+
+// riskRuleFactories are the rule types that can be configured, by type name
+var riskRuleFactories = map[string]RiskRuleFactory{}
+
+// Global variable with the rules loaded from RISK_RULES_FILE, evaluated in order
+var riskRules []RiskRule
+
+// riskActivityWindow is how far back the recent activity handed to the rules goes
+const riskActivityWindow = 24 * time.Hour
+
+// RegisterRiskRule makes a rule type available to RISK_RULES_FILE
+func RegisterRiskRule(ruleType string, factory RiskRuleFactory) {
+	riskRuleFactories[ruleType] = factory
+}
+
+// riskRulesFile is the layout of RISK_RULES_FILE
+type riskRulesFile struct {
+	Rules []yaml.Node `yaml:"rules"`
+}
+
+// riskRuleEntry holds the settings every rule has
+type riskRuleEntry struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Action string `yaml:"action"`
+}
+
+// LoadRiskRules loads the rules listed in RISK_RULES_FILE. Without a file every transaction is allowed.
+func LoadRiskRules() {
+	riskRules = nil
+	if Config.RiskRulesFile == "" {
+		return
+	}
+
+	content, err := os.ReadFile(Config.RiskRulesFile)
+	if err != nil {
+		panic(fmt.Errorf("cannot read RISK_RULES_FILE: %w", err))
+	}
+
+	var file riskRulesFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		panic(fmt.Errorf("invalid RISK_RULES_FILE: %w", err))
+	}
+
+	rules := make([]RiskRule, 0, len(file.Rules))
+	for i := range file.Rules {
+		var entry riskRuleEntry
+		if err := file.Rules[i].Decode(&entry); err != nil {
+			panic(fmt.Errorf("invalid rule %d in RISK_RULES_FILE: %w", i+1, err))
+		}
+		factory, ok := riskRuleFactories[entry.Type]
+		if !ok {
+			panic(fmt.Errorf("invalid rule %d in RISK_RULES_FILE, unknown type %q", i+1, entry.Type))
+		}
+		if entry.Action != models.RiskActionDeny && entry.Action != models.RiskActionHold {
+			panic(fmt.Errorf("invalid rule %d in RISK_RULES_FILE, action must be deny or hold", i+1))
+		}
+		if entry.Name == "" {
+			entry.Name = entry.Type
+		}
+
+		rule, err := factory(entry.Name, entry.Action, &file.Rules[i])
+		if err != nil {
+			panic(fmt.Errorf("invalid rule %q in RISK_RULES_FILE: %w", entry.Name, err))
+		}
+		rules = append(rules, rule)
+	}
+
+	riskRules = rules
+	logger.Info("Loaded risk rules", zap.Int("rules", len(rules)))
+}
+
+// evaluateRisk runs the risk rules on a transaction, with the recent activity of the user read through db.
+// It returns nil when every rule allows the transaction.
+func evaluateRisk(db *gorm.DB, logCtx context.Context, input models.RiskInput) (*models.RiskDecision, error) {
+	if len(riskRules) == 0 {
+		return nil, nil
+	}
+
+	err := db.
+		Where("user_id = ? AND created_at >= ?", input.User.ID, time.Now().Add(-riskActivityWindow)).
+		Order("created_at desc").
+		Find(&input.RecentActivity).Error
+	if err != nil {
+		return nil, classifyDBError(err, "Transactions not found")
+	}
+	return decideRisk(logCtx, input), nil
+}
+
+// decideRisk runs the risk rules on a transaction. A deny of any rule wins over a hold.
+func decideRisk(logCtx context.Context, input models.RiskInput) *models.RiskDecision {
+	var held *models.RiskDecision
+	for _, rule := range riskRules {
+		decision := rule.Evaluate(input)
+		if decision == nil {
+			continue
+		}
+		metrics.RiskDecisions.WithLabelValues(decision.Rule, decision.Action).Inc()
+		logger.InfoCtx(logCtx, "Risk rule matched", zap.String("rule", decision.Rule), zap.String("action", decision.Action), zap.String("reason", decision.Reason))
+
+		if decision.Action == models.RiskActionDeny {
+			return decision
+		}
+		if held == nil {
+			held = decision
+		}
+	}
+	return held
+}
+
+// applyRiskDecision turns a decision into the denial error, or records the transaction as held for review through db
+func applyRiskDecision(db *gorm.DB, input models.RiskInput, decision *models.RiskDecision, transactionID string) (*models.RiskHold, error) {
+	if decision.Action == models.RiskActionDeny {
+		return nil, models.NewError(models.ErrRiskDenied, "Transaction was denied: "+decision.Reason)
+	}
+
+	hold := models.RiskHold{
+		TransactionID: transactionID,
+		UserID:        input.User.ID,
+		UID:           input.User.UID,
+		Wallet:        input.Wallet,
+		ToWallet:      input.ToWallet,
+		Type:          input.Type,
+		Amount:        input.Amount,
+		Rule:          decision.Rule,
+		Reason:        decision.Reason,
+		Status:        models.HoldStatusPending,
+	}
+	if len(input.Metadata) > 0 {
+		metadata, err := json.Marshal(input.Metadata)
+		if err != nil {
+			return nil, models.WrapError(models.ErrValidation, "invalid metadata", err)
+		}
+		hold.Metadata = postgres.Jsonb{RawMessage: metadata}
+	}
+
+	if err := db.Create(&hold).Error; err != nil {
+		return nil, classifyDBError(err, "Hold not found")
+	}
+	return &hold, nil
+}
+
+// ListHolds returns the held transactions in the state, oldest first
+func ListHolds(ctx context.Context, status string) ([]models.RiskHold, error) {
+	var holds []models.RiskHold
+	if err := dbWithContext(ctx).Where("status = ?", status).Order("created_at").Find(&holds).Error; err != nil {
+		return nil, classifyDBError(err, "Holds not found")
+	}
+	return holds, nil
+}
+
+// ApproveHold writes a held transaction without evaluating the risk rules again.
+// The account state, balance and velocity limits are still checked; when they reject it the hold stays pending.
+func ApproveHold(ctx context.Context, transactionID string, request models.ReviewHoldRequest, actor string) (models.RiskHold, error) {
+	hold, err := claimHold(ctx, transactionID, models.HoldStatusApproved, request, actor)
+	if err != nil {
+		return models.RiskHold{}, err
+	}
+
+	var metadata map[string]string
+	if len(hold.Metadata.RawMessage) > 0 {
+		_ = json.Unmarshal(hold.Metadata.RawMessage, &metadata)
+	}
+
+	switch hold.Type {
+	case models.TransactionTypeCredit:
+		_, err = postCredit(ctx, hold.UID, hold.Wallet, models.AddFundsRequest{Amount: hold.Amount, Metadata: metadata}, hold.TransactionID, true)
+	case models.TransactionTypeMoveOut:
+		_, err = postMove(ctx, hold.UID, hold.Wallet, models.MoveFundsRequest{ToWallet: hold.ToWallet, Amount: hold.Amount, Metadata: metadata}, hold.TransactionID, true)
+	case models.TransactionTypeDebit:
+		// Debits are only made by batches
+		err = postHeldDebit(ctx, hold, metadata)
+	case models.TransactionTypeFxOut:
+		// Only conversions without a quote are held, so the conversion is made at the current rate
+		_, err = postConversion(ctx, hold.UID, hold.Wallet, models.ConvertFundsRequest{ToWallet: hold.ToWallet, Amount: hold.Amount, Metadata: metadata}, hold.TransactionID, true)
+	default:
+		err = models.NewError(models.ErrConflict, "Held transactions of type "+hold.Type+" cannot be approved")
+	}
+	if err != nil {
+		// Put the hold back in the queue so it can be approved once the account allows it, or rejected
+		reopenErr := dbWithContext(ctx).Model(&hold).Updates(map[string]interface{}{
+			"status":      models.HoldStatusPending,
+			"reviewed_by": "",
+			"review_note": "",
+			"reviewed_at": nil,
+		}).Error
+		if reopenErr != nil {
+			logger.ErrorCtx(ctx, "Error reopening hold", zap.String("transaction_id", transactionID), zap.Error(reopenErr))
+		}
+		return models.RiskHold{}, err
+	}
+
+	return hold, nil
+}
+
+// RejectHold rejects a held transaction, which is then never written
+func RejectHold(ctx context.Context, transactionID string, request models.ReviewHoldRequest, actor string) (models.RiskHold, error) {
+	return claimHold(ctx, transactionID, models.HoldStatusRejected, request, actor)
+}
+
+// claimHold moves a pending hold to the review outcome. Only one reviewer can claim a hold.
+func claimHold(ctx context.Context, transactionID string, status string, request models.ReviewHoldRequest, actor string) (models.RiskHold, error) {
+	db := dbWithContext(ctx)
+
+	var hold models.RiskHold
+	if err := db.Where("transaction_id = ?", transactionID).First(&hold).Error; err != nil {
+		return models.RiskHold{}, classifyDBError(err, "Hold not found")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.RiskHold{}).
+		Where("id = ? AND status = ?", hold.ID, models.HoldStatusPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": actor,
+			"review_note": request.Note,
+			"reviewed_at": &now,
+		})
+	if result.Error != nil {
+		return models.RiskHold{}, classifyDBError(result.Error, "Hold not found")
+	}
+	if result.RowsAffected == 0 {
+		return models.RiskHold{}, models.NewError(models.ErrConflict, "Hold was already reviewed")
+	}
+
+	hold.Status = status
+	hold.ReviewedBy = actor
+	hold.ReviewNote = request.Note
+	hold.ReviewedAt = &now
+	logger.InfoCtx(ctx, "Hold reviewed", zap.String("transaction_id", transactionID), zap.String("status", status), zap.String("actor", actor))
+	return hold, nil
+}
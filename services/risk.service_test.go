@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withRiskRules sets the risk rules for a test and restores the loaded ones after it
+func withRiskRules(t *testing.T, rules []RiskRule) {
+	loaded := riskRules
+	riskRules = rules
+	t.Cleanup(func() { riskRules = loaded })
+}
+
+func TestLoadRiskRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantRules []RiskRule
+		wantPanic string
+	}{
+		{
+			"every rule type",
+			"rules:\n" +
+				"  - {name: very_large_amount, type: large_amount, action: deny, amount: 1000000}\n" +
+				"  - {name: structuring, type: many_small_credits, action: hold, max_amount: 100, count: 20, window: 1h}\n" +
+				"  - {type: new_account, action: hold, min_age: 72h, amount: 1000}\n",
+			[]RiskRule{
+				&largeAmountRule{name: "very_large_amount", action: models.RiskActionDeny, Amount: 1000000},
+				&manySmallCreditsRule{name: "structuring", action: models.RiskActionHold, MaxAmount: 100, Count: 20, Window: time.Hour},
+				&newAccountRule{name: "new_account", action: models.RiskActionHold, MinAge: 72 * time.Hour, Amount: 1000},
+			},
+			"",
+		},
+		{"no rules", "rules: []\n", []RiskRule{}, ""},
+		{"unknown type", "rules:\n  - {type: velocity, action: deny}\n", nil, `invalid rule 1 in RISK_RULES_FILE, unknown type "velocity"`},
+		{"unknown action", "rules:\n  - {type: large_amount, action: flag, amount: 10}\n", nil, "invalid rule 1 in RISK_RULES_FILE, action must be deny or hold"},
+		{"invalid settings", "rules:\n  - {name: big, type: large_amount, action: deny, amount: 0}\n", nil, `invalid rule "big" in RISK_RULES_FILE: amount must be positive`},
+		{"window beyond the recent activity", "rules:\n  - {type: many_small_credits, action: hold, max_amount: 100, count: 2, window: 48h}\n", nil,
+			`invalid rule "many_small_credits" in RISK_RULES_FILE: window must be positive and at most 24h0m0s`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "risk_rules.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			withConfig(t, models.EnvConfig{RiskRulesFile: path})
+			withRiskRules(t, nil)
+
+			if tt.wantPanic != "" {
+				require.PanicsWithError(t, tt.wantPanic, LoadRiskRules)
+				return
+			}
+			LoadRiskRules()
+			require.Equal(t, tt.wantRules, riskRules)
+		})
+	}
+}
+
+func TestLoadRiskRulesWithoutFile(t *testing.T) {
+	withConfig(t, models.EnvConfig{})
+	withRiskRules(t, []RiskRule{&largeAmountRule{name: "large_amount", action: models.RiskActionDeny, Amount: 1}})
+
+	LoadRiskRules()
+	require.Empty(t, riskRules)
+}
+
+func TestDecideRisk(t *testing.T) {
+	holdLarge := &largeAmountRule{name: "hold_large", action: models.RiskActionHold, Amount: 100}
+	holdLarger := &largeAmountRule{name: "hold_larger", action: models.RiskActionHold, Amount: 500}
+	denyHuge := &largeAmountRule{name: "deny_huge", action: models.RiskActionDeny, Amount: 1000}
+
+	tests := []struct {
+		name     string
+		rules    []RiskRule
+		amount   float64
+		wantRule string
+	}{
+		{"no rule matches", []RiskRule{holdLarge, denyHuge}, 50, ""},
+		{"hold", []RiskRule{holdLarge, denyHuge}, 200, "hold_large"},
+		{"deny wins over an earlier hold", []RiskRule{holdLarge, denyHuge}, 2000, "deny_huge"},
+		{"deny wins over a later hold", []RiskRule{denyHuge, holdLarge}, 2000, "deny_huge"},
+		{"first hold wins", []RiskRule{holdLarger, holdLarge}, 600, "hold_larger"},
+		{"no rules", nil, 2000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRiskRules(t, tt.rules)
+
+			decision := decideRisk(context.Background(), models.RiskInput{Type: models.TransactionTypeCredit, Amount: tt.amount})
+			if tt.wantRule == "" {
+				require.Nil(t, decision)
+				return
+			}
+			require.NotNil(t, decision)
+			require.Equal(t, tt.wantRule, decision.Rule)
+		})
+	}
+}
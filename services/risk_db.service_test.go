@@ -0,0 +1,133 @@
+//go:build cgo
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withRiskDB points DbConnection at an in-memory SQLite database with the tables the risk checks use for a test,
+// and restores the connection after it
+func withRiskDB(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: opens a database of its own
+	db.DB().SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.Transaction{}, &models.RiskHold{}).Error)
+
+	loaded := DbConnection
+	DbConnection = db
+	t.Cleanup(func() {
+		DbConnection = loaded
+		db.Close()
+	})
+}
+
+func TestClaimHold(t *testing.T) {
+	withRiskDB(t)
+	ctx := context.Background()
+	require.NoError(t, DbConnection.Create(&models.RiskHold{
+		TransactionID: "credit-1", UserID: 1, UID: "user-1", Wallet: "main",
+		Type: models.TransactionTypeCredit, Amount: 500, Rule: "large_amount", Status: models.HoldStatusPending,
+	}).Error)
+
+	hold, err := claimHold(ctx, "credit-1", models.HoldStatusApproved, models.ReviewHoldRequest{Note: "customer called"}, "alice")
+	require.NoError(t, err)
+	require.Equal(t, models.HoldStatusApproved, hold.Status)
+	require.Equal(t, "alice", hold.ReviewedBy)
+	require.Equal(t, "customer called", hold.ReviewNote)
+	require.NotNil(t, hold.ReviewedAt)
+
+	_, err = claimHold(ctx, "credit-1", models.HoldStatusRejected, models.ReviewHoldRequest{}, "bob")
+	require.True(t, errors.Is(err, models.ErrConflict))
+
+	var stored models.RiskHold
+	require.NoError(t, DbConnection.Where("transaction_id = ?", "credit-1").First(&stored).Error)
+	require.Equal(t, models.HoldStatusApproved, stored.Status)
+	require.Equal(t, "alice", stored.ReviewedBy)
+
+	_, err = claimHold(ctx, "credit-2", models.HoldStatusApproved, models.ReviewHoldRequest{}, "alice")
+	require.True(t, errors.Is(err, models.ErrNotFound))
+}
+
+func TestClaimHoldConcurrentReviews(t *testing.T) {
+	withRiskDB(t)
+	require.NoError(t, DbConnection.Create(&models.RiskHold{
+		TransactionID: "move-1", UserID: 1, UID: "user-1", Wallet: "main", ToWallet: "savings",
+		Type: models.TransactionTypeMoveOut, Amount: 500, Rule: "large_amount", Status: models.HoldStatusPending,
+	}).Error)
+
+	const reviewers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, reviewers)
+	for i := 0; i < reviewers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status := models.HoldStatusApproved
+			if i%2 == 1 {
+				status = models.HoldStatusRejected
+			}
+			_, errs[i] = claimHold(context.Background(), "move-1", status, models.ReviewHoldRequest{}, "reviewer")
+		}(i)
+	}
+	wg.Wait()
+
+	claimed := 0
+	for _, err := range errs {
+		if err == nil {
+			claimed++
+			continue
+		}
+		require.True(t, errors.Is(err, models.ErrConflict))
+	}
+	require.Equal(t, 1, claimed)
+}
+
+func TestEvaluateRisk(t *testing.T) {
+	withRiskDB(t)
+	withRiskRules(t, []RiskRule{
+		&manySmallCreditsRule{name: "structuring", action: models.RiskActionHold, MaxAmount: 100, Count: 2, Window: time.Hour},
+		&largeAmountRule{name: "deny_large", action: models.RiskActionDeny, Amount: 50},
+	})
+	ctx := context.Background()
+	user := models.User{Model: gorm.Model{ID: 1}, UID: "user-1"}
+	input := models.RiskInput{User: user, Wallet: "main", Type: models.TransactionTypeCredit, Amount: 60}
+
+	decision, err := evaluateRisk(DbConnection, ctx, input)
+	require.NoError(t, err)
+	require.Equal(t, "deny_large", decision.Rule)
+
+	// Credits written in a transaction count for the checks made through it, before it is committed
+	tx := DbConnection.Begin()
+	defer tx.Rollback()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, tx.Create(&models.Transaction{UserID: user.ID, WalletID: 1, Amount: 20, Type: models.TransactionTypeCredit, TransactionID: fmt.Sprintf("credit-%d", i), Currency: "USD"}).Error)
+	}
+	input.Amount = 40
+	decision, err = evaluateRisk(tx, ctx, input)
+	require.NoError(t, err)
+	require.Equal(t, "structuring", decision.Rule)
+	require.Equal(t, models.RiskActionHold, decision.Action)
+
+	// A deny of any rule wins over the hold
+	input.Amount = 80
+	decision, err = evaluateRisk(tx, ctx, input)
+	require.NoError(t, err)
+	require.Equal(t, "deny_large", decision.Rule)
+
+	input.Amount = 40
+	withRiskRules(t, nil)
+	decision, err = evaluateRisk(tx, ctx, input)
+	require.NoError(t, err)
+	require.Nil(t, decision)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"ledger-service/models"
+	"time"
+)
+
+func init() {
+	RegisterRiskRule("large_amount", newLargeAmountRule)
+	RegisterRiskRule("many_small_credits", newManySmallCreditsRule)
+	RegisterRiskRule("new_account", newNewAccountRule)
+}
+
+// largeAmountRule matches credits, debits and moves of at least Amount
+type largeAmountRule struct {
+	name   string
+	action string
+	Amount float64 `yaml:"amount"`
+}
+
+func newLargeAmountRule(name string, action string, config *yaml.Node) (RiskRule, error) {
+	rule := &largeAmountRule{name: name, action: action}
+	if err := config.Decode(rule); err != nil {
+		return nil, err
+	}
+	if rule.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	return rule, nil
+}
+
+func (r *largeAmountRule) Evaluate(input models.RiskInput) *models.RiskDecision {
+	if input.Amount < r.Amount {
+		return nil
+	}
+	return &models.RiskDecision{
+		Action: r.action,
+		Rule:   r.name,
+		Reason: fmt.Sprintf("amount of %.2f is at least %.2f", input.Amount, r.Amount),
+	}
+}
+
+// manySmallCreditsRule matches a credit of at most MaxAmount when the user already received Count such credits within Window
+type manySmallCreditsRule struct {
+	name      string
+	action    string
+	MaxAmount float64       `yaml:"max_amount"`
+	Count     int           `yaml:"count"`
+	Window    time.Duration `yaml:"window"`
+}
+
+func newManySmallCreditsRule(name string, action string, config *yaml.Node) (RiskRule, error) {
+	rule := &manySmallCreditsRule{name: name, action: action}
+	if err := config.Decode(rule); err != nil {
+		return nil, err
+	}
+	switch {
+	case rule.MaxAmount <= 0:
+		return nil, errors.New("max_amount must be positive")
+	case rule.Count <= 0:
+		return nil, errors.New("count must be positive")
+	case rule.Window <= 0 || rule.Window > riskActivityWindow:
+		return nil, fmt.Errorf("window must be positive and at most %s", riskActivityWindow)
+	}
+	return rule, nil
+}
+
+func (r *manySmallCreditsRule) Evaluate(input models.RiskInput) *models.RiskDecision {
+	if input.Type != models.TransactionTypeCredit || input.Amount > r.MaxAmount {
+		return nil
+	}
+
+	since := time.Now().Add(-r.Window)
+	count := 0
+	for _, transaction := range input.RecentActivity {
+		if transaction.Type == models.TransactionTypeCredit && transaction.Amount <= r.MaxAmount && transaction.CreatedAt.After(since) {
+			count++
+		}
+	}
+	if count < r.Count {
+		return nil
+	}
+	return &models.RiskDecision{
+		Action: r.action,
+		Rule:   r.name,
+		Reason: fmt.Sprintf("%d credits of at most %.2f within %s", count+1, r.MaxAmount, r.Window),
+	}
+}
+
+// newAccountRule matches transactions of at least Amount on accounts younger than MinAge
+type newAccountRule struct {
+	name   string
+	action string
+	MinAge time.Duration `yaml:"min_age"`
+	Amount float64       `yaml:"amount"`
+}
+
+func newNewAccountRule(name string, action string, config *yaml.Node) (RiskRule, error) {
+	rule := &newAccountRule{name: name, action: action}
+	if err := config.Decode(rule); err != nil {
+		return nil, err
+	}
+	if rule.MinAge <= 0 {
+		return nil, errors.New("min_age must be positive")
+	}
+	if rule.Amount < 0 {
+		return nil, errors.New("amount cannot be negative")
+	}
+	return rule, nil
+}
+
+func (r *newAccountRule) Evaluate(input models.RiskInput) *models.RiskDecision {
+	age := time.Since(input.User.CreatedAt)
+	if age >= r.MinAge || input.Amount < r.Amount {
+		return nil
+	}
+	return &models.RiskDecision{
+		Action: r.action,
+		Rule:   r.name,
+		Reason: fmt.Sprintf("account is %s old, younger than %s", age.Truncate(time.Minute), r.MinAge),
+	}
+}
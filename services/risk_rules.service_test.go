@@ -0,0 +1,90 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+	"time"
+)
+
+func TestLargeAmountRule(t *testing.T) {
+	rule := &largeAmountRule{name: "large_amount", action: models.RiskActionHold, Amount: 10000}
+
+	tests := []struct {
+		name   string
+		amount float64
+		want   *models.RiskDecision
+	}{
+		{"below the amount", 9999.99, nil},
+		{"at the amount", 10000, &models.RiskDecision{Action: models.RiskActionHold, Rule: "large_amount", Reason: "amount of 10000.00 is at least 10000.00"}},
+		{"above the amount", 25000, &models.RiskDecision{Action: models.RiskActionHold, Rule: "large_amount", Reason: "amount of 25000.00 is at least 10000.00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.RiskInput{Type: models.TransactionTypeMoveOut, Amount: tt.amount}
+			require.Equal(t, tt.want, rule.Evaluate(input))
+		})
+	}
+}
+
+func TestManySmallCreditsRule(t *testing.T) {
+	rule := &manySmallCreditsRule{name: "structuring", action: models.RiskActionHold, MaxAmount: 100, Count: 2, Window: time.Hour}
+	now := time.Now()
+	credit := func(amount float64, ago time.Duration) models.Transaction {
+		return models.Transaction{Type: models.TransactionTypeCredit, Amount: amount, Model: gorm.Model{CreatedAt: now.Add(-ago)}}
+	}
+
+	tests := []struct {
+		name     string
+		txType   string
+		amount   float64
+		activity []models.Transaction
+		want     *models.RiskDecision
+	}{
+		{"first small credit", models.TransactionTypeCredit, 50, nil, nil},
+		{"fewer than count before", models.TransactionTypeCredit, 50, []models.Transaction{credit(20, time.Minute)}, nil},
+		{"count small credits before", models.TransactionTypeCredit, 50, []models.Transaction{credit(20, time.Minute), credit(100, 30*time.Minute)},
+			&models.RiskDecision{Action: models.RiskActionHold, Rule: "structuring", Reason: "3 credits of at most 100.00 within 1h0m0s"}},
+		{"credits outside the window", models.TransactionTypeCredit, 50, []models.Transaction{credit(20, time.Minute), credit(20, 2*time.Hour)}, nil},
+		{"larger credits do not count", models.TransactionTypeCredit, 50, []models.Transaction{credit(20, time.Minute), credit(500, time.Minute)}, nil},
+		{"other types do not count", models.TransactionTypeCredit, 50, []models.Transaction{credit(20, time.Minute), {Type: models.TransactionTypeMoveIn, Amount: 20, Model: gorm.Model{CreatedAt: now}}}, nil},
+		{"larger credit", models.TransactionTypeCredit, 150, []models.Transaction{credit(20, time.Minute), credit(20, time.Minute)}, nil},
+		{"move", models.TransactionTypeMoveOut, 50, []models.Transaction{credit(20, time.Minute), credit(20, time.Minute)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := models.RiskInput{Type: tt.txType, Amount: tt.amount, RecentActivity: tt.activity}
+			require.Equal(t, tt.want, rule.Evaluate(input))
+		})
+	}
+}
+
+func TestNewAccountRule(t *testing.T) {
+	rule := &newAccountRule{name: "new_account", action: models.RiskActionDeny, MinAge: 72 * time.Hour, Amount: 1000}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		amount   float64
+		wantRule bool
+	}{
+		{"new account at the amount", time.Hour, 1000, true},
+		{"new account below the amount", time.Hour, 999, false},
+		{"old account", 100 * time.Hour, 5000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := models.User{Model: gorm.Model{CreatedAt: time.Now().Add(-tt.age)}}
+			decision := rule.Evaluate(models.RiskInput{User: user, Type: models.TransactionTypeCredit, Amount: tt.amount})
+			if !tt.wantRule {
+				require.Nil(t, decision)
+				return
+			}
+			require.NotNil(t, decision)
+			require.Equal(t, models.RiskActionDeny, decision.Action)
+			require.Equal(t, "new_account", decision.Rule)
+			require.Equal(t, "account is 1h0m0s old, younger than 72h0m0s", decision.Reason)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fmt"
+	"ledger-service/models"
+	"strings"
+)
+
+// Global variable mapping user emails to the role configured for them in AUTH_ROLES
+var rolesByEmail = map[string]string{}
+
+// LoadRoles loads the email=role assignments listed in AUTH_ROLES.
+// Users without an assignment get AUTH_DEFAULT_ROLE, which grants no scopes when it is not set.
+func LoadRoles() {
+	roles := map[string]string{}
+	for _, entry := range Config.AuthRoles {
+		email, role, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || email == "" {
+			panic(fmt.Errorf("invalid AUTH_ROLES entry %q, expected email=role", entry))
+		}
+		if _, ok := models.RoleScopes[role]; !ok {
+			panic(fmt.Errorf("invalid AUTH_ROLES entry %q, unknown role %q", entry, role))
+		}
+		roles[strings.ToLower(email)] = role
+	}
+
+	rolesByEmail = roles
+}
+
+// RoleForEmail returns the role assigned to the user with the given email
+func RoleForEmail(email string) string {
+	if role, ok := rolesByEmail[strings.ToLower(email)]; ok {
+		return role
+	}
+	return Config.AuthDefaultRole
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/metrics"
+	"ledger-service/models"
+	"time"
+)
+
+// Names of the scheduled jobs, used in their leader locks, job runs and metrics
+const (
+	JobAccrueInterest     = "accrue_interest"
+	JobPostInterest       = "post_interest"
+	JobRecurringTransfers = "recurring_transfers"
+)
+
+// schedulerLockTTL is how long an instance stays leader of a job without finishing it
+const schedulerLockTTL = 10 * time.Minute
+
+// dayFormat is the period of daily jobs
+const dayFormat = "2006-01-02"
+
+// Global variable with the func that stops the scheduler started by StartScheduler
+var stopScheduler context.CancelFunc
+
+// StartScheduler runs the scheduled jobs every SCHEDULER_INTERVAL_SECONDS until StopScheduler is called.
+// Every instance runs the scheduler; a Redis lock per job makes sure only one of them runs a job at a time.
+func StartScheduler() {
+	if !Config.SchedulerEnabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopScheduler = cancel
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(Config.SchedulerIntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			runScheduledJobs(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Info("Scheduler started", zap.Int("interval_seconds", Config.SchedulerIntervalSeconds))
+}
+
+// StopScheduler stops starting new jobs. Postings already running are drained like other ledger writes.
+func StopScheduler() {
+	if stopScheduler != nil {
+		stopScheduler()
+	}
+}
+
+// runScheduledJobs runs every job that is due
+func runScheduledJobs(ctx context.Context) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if len(interestRates) > 0 {
+		// Days missed while no instance ran the job are accrued in order, so a failed day is retried before the next
+		for day := firstAccrualDay(ctx, yesterday); !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+			done := runJob(ctx, JobAccrueInterest, day.Format(dayFormat), func(ctx context.Context) error {
+				return accrueInterest(ctx, day)
+			})
+			if !done {
+				break
+			}
+		}
+	}
+	// Posting also runs without rates, so interest accrued before the rates were removed is still paid out
+	runJob(ctx, JobPostInterest, monthStart.AddDate(0, -1, 0).Format("2006-01"), func(ctx context.Context) error {
+		return postInterest(ctx, monthStart)
+	})
+	runJob(ctx, JobRecurringTransfers, "", func(ctx context.Context) error {
+		return runRecurringTransfers(ctx, now)
+	})
+}
+
+// firstAccrualDay returns the day after the last day interest was accrued for, or yesterday when it never was
+func firstAccrualDay(ctx context.Context, yesterday time.Time) time.Time {
+	var last sql.NullString
+	err := dbWithContext(ctx).Model(&models.JobRun{}).Where("job = ?", JobAccrueInterest).Select("MAX(period)").Row().Scan(&last)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Error checking job runs", zap.String("job", JobAccrueInterest), zap.Error(err))
+		return yesterday
+	}
+	if !last.Valid {
+		return yesterday
+	}
+	day, err := time.Parse(dayFormat, last.String)
+	if err != nil {
+		return yesterday
+	}
+	return day.AddDate(0, 0, 1)
+}
+
+// runJob runs a job on the instance that acquires its lock and tells whether the job is done for the period.
+// Jobs with a period are recorded as done for it once they succeed and are not run for it again; every job still
+// has to be safe to rerun after a failure.
+func runJob(ctx context.Context, job string, period string, run func(ctx context.Context) error) bool {
+	c := logger.WithFields(ctx, zap.String("job", job))
+	db := dbWithContext(c)
+
+	rs := redsync.New(goredis.NewPool(GetRedisDefaultClient()))
+	mutex := rs.NewMutex("scheduler:"+job, redsync.WithTries(1), redsync.WithExpiry(schedulerLockTTL))
+	if err := mutex.LockContext(c); err != nil {
+		// Another instance is running the job
+		return false
+	}
+	defer func() {
+		if _, err := mutex.Unlock(); err != nil {
+			logger.ErrorCtx(c, "Error releasing scheduler lock", zap.Error(err))
+		}
+	}()
+
+	// Checked under the lock, so an instance that was waiting on it does not run the period again
+	if period != "" {
+		var count int
+		if err := db.Model(&models.JobRun{}).Where("job = ? AND period = ?", job, period).Count(&count).Error; err != nil {
+			logger.ErrorCtx(c, "Error checking job runs", zap.Error(err))
+			return false
+		}
+		if count > 0 {
+			return true
+		}
+	}
+
+	start := time.Now()
+	err := run(c)
+	if err == nil && period != "" {
+		err = db.Create(&models.JobRun{Job: job, Period: period}).Error
+	}
+	metrics.ScheduledJobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.ScheduledJobRuns.WithLabelValues(job, "error").Inc()
+		logger.ErrorCtx(c, "Scheduled job failed", zap.String("period", period), zap.Error(err))
+		return false
+	}
+	metrics.ScheduledJobRuns.WithLabelValues(job, "success").Inc()
+	logger.DebugCtx(c, "Scheduled job done", zap.String("period", period))
+	return true
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"github.com/go-redsync/redsync/v4"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+	"sync"
+)
+
+// ErrShuttingDown is returned for ledger writes that start after the instance began draining them
+var ErrShuttingDown = models.NewError(models.ErrDependencyUnavailable, "service is shutting down")
+
+// Global variables tracking in-flight ledger writes. The instance reports not ready as soon as the shutdown begins,
+// but keeps accepting writes until they are drained, while the load balancer may still route requests to it.
+var (
+	ledgerWritesMu sync.Mutex
+	ledgerWrites   sync.WaitGroup
+	shuttingDown   bool
+	draining       bool
+)
+
+// beginLedgerWrite registers a ledger write so the shutdown waits for it. The returned func must be called when it is done.
+func beginLedgerWrite() (func(), error) {
+	ledgerWritesMu.Lock()
+	defer ledgerWritesMu.Unlock()
+
+	if draining {
+		return nil, ErrShuttingDown
+	}
+	ledgerWrites.Add(1)
+	return ledgerWrites.Done, nil
+}
+
+// releaseLock unlocks a lock taken with lockWallet
+func releaseLock(mutex *redsync.Mutex) {
+	if _, err := mutex.Unlock(); err != nil {
+		logger.Error("Error releasing lock", zap.String("lock", mutex.Name()), zap.Error(err))
+	}
+}
+
+// BeginShutdown marks the instance as not ready. Ledger writes are still accepted until DrainLedgerWrites.
+func BeginShutdown() {
+	ledgerWritesMu.Lock()
+	defer ledgerWritesMu.Unlock()
+	shuttingDown = true
+}
+
+// IsShuttingDown reports whether BeginShutdown was called
+func IsShuttingDown() bool {
+	ledgerWritesMu.Lock()
+	defer ledgerWritesMu.Unlock()
+	return shuttingDown
+}
+
+// DrainLedgerWrites rejects new ledger writes and waits for the in-flight ones until the context is done.
+// Writes still running when the context expires keep their locks: another instance may only take them once they
+// expire, since releasing them early would let it write the same wallet while the balance here is still being written.
+func DrainLedgerWrites(ctx context.Context) error {
+	ledgerWritesMu.Lock()
+	shuttingDown = true
+	draining = true
+	ledgerWritesMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ledgerWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseConnections closes the database and Redis clients
+func CloseConnections() {
+	if DbConnection != nil {
+		if err := DbConnection.Close(); err != nil {
+			logger.Error("Error closing the Database connection", zap.Error(err))
+		}
+	}
+
+	if redisDefaultClient != nil {
+		if err := redisDefaultClient.Close(); err != nil {
+			logger.Error("Error closing the Redis connection", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Global variable mapping signing client IDs to their HMAC secrets as configured in REQUEST_SIGNING_SECRETS
+var requestSigningSecrets = map[string][]byte{}
+
+// LoadRequestSigningSecrets loads the client=secret pairs listed in REQUEST_SIGNING_SECRETS
+func LoadRequestSigningSecrets() {
+	secrets := map[string][]byte{}
+	for _, entry := range Config.RequestSigningSecrets {
+		clientID, secret, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || clientID == "" || len(secret) < 32 {
+			panic(fmt.Errorf("invalid REQUEST_SIGNING_SECRETS entry for %q, expected client=secret with a secret of at least 32 characters", clientID))
+		}
+		secrets[clientID] = []byte(secret)
+	}
+
+	requestSigningSecrets = secrets
+}
+
+// SignedRequest holds the parts of a request covered by its X-Signature header
+type SignedRequest struct {
+	ClientID  string
+	Method    string
+	Path      string
+	Timestamp string
+	Nonce     string
+	Body      []byte
+	Signature string
+}
+
+// StringToSign returns the canonical form of the request that is signed by the client:
+// method, path, unix timestamp, nonce and the hex SHA-256 of the body, separated by newlines.
+func (request SignedRequest) StringToSign() string {
+	bodyHash := sha256.Sum256(request.Body)
+	return strings.Join([]string{
+		strings.ToUpper(request.Method),
+		request.Path,
+		request.Timestamp,
+		request.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// VerifyRequestSignature checks the HMAC-SHA256 signature of the request, rejects timestamps outside the allowed skew
+// and remembers the nonce in Redis so the same request cannot be replayed while its timestamp is still accepted.
+func VerifyRequestSignature(ctx context.Context, request SignedRequest) error {
+	secret, ok := requestSigningSecrets[request.ClientID]
+	if !ok {
+		return errors.New("unknown signing client")
+	}
+
+	timestamp, err := strconv.ParseInt(request.Timestamp, 10, 64)
+	if err != nil {
+		return errors.New("not valid signature timestamp")
+	}
+	maxSkew := time.Duration(Config.RequestSigningMaxSkew) * time.Second
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > maxSkew || skew < -maxSkew {
+		return errors.New("signature timestamp is stale")
+	}
+
+	if request.Nonce == "" {
+		return errors.New("signature nonce is required")
+	}
+
+	signature, err := hex.DecodeString(request.Signature)
+	if err != nil {
+		return errors.New("not valid signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(request.StringToSign()))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("not valid signature")
+	}
+
+	// A nonce only has to be remembered for as long as its timestamp can pass the skew check
+	nonceKey := fmt.Sprintf("signature_nonce:%s:%s", request.ClientID, request.Nonce)
+	stored, err := GetRedisDefaultClient().SetNX(ctx, nonceKey, request.Timestamp, 2*maxSkew).Result()
+	if err != nil {
+		return fmt.Errorf("cannot store signature nonce %v", err)
+	}
+	if !stored {
+		return errors.New("signature nonce has already been used")
+	}
+
+	return nil
+}
@@ -2,13 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/jinzhu/gorm"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 	"ledger-service/logger"
 	"ledger-service/models"
+	"net"
 	"sync"
 	"time"
 )
@@ -16,6 +18,24 @@ import (
 // Global variable to hold the database connection
 var DbConnection *gorm.DB
 
+// migratedModels are the tables created by AutoMigrate on startup
+var migratedModels = []interface{}{
+	&models.User{},
+	&models.Wallet{},
+	&models.Transaction{},
+	&models.Token{},
+	&models.APIKey{},
+	&models.AccountStatusChange{},
+	&models.LimitChange{},
+	&models.RiskHold{},
+	&models.InterestAccrual{},
+	&models.RecurringTransfer{},
+	&models.JobRun{},
+	&models.FxRate{},
+	&models.Batch{},
+	&models.BatchItem{},
+}
+
 // Constants to set the number of retries and delay between retries
 const (
 	retries = 5
@@ -36,10 +56,11 @@ func ConnectDB() {
 		db, err = gorm.Open("postgres", dsn)
 		if err == nil {
 			DbConnection = db
+			registerDBMetrics(DbConnection)
+			registerDBTracing(DbConnection)
 			// AutoMigrate the tables for the models
-			DbConnection.AutoMigrate(&models.User{})
-			DbConnection.AutoMigrate(&models.Transaction{})
-			DbConnection.AutoMigrate(&models.Token{})
+			DbConnection.AutoMigrate(migratedModels...)
+			migrateWallets()
 			logger.Info("Successfully connected to the Database")
 			return
 		}
@@ -52,6 +73,55 @@ func ConnectDB() {
 	logger.Fatal("Failed to connect to the Database after multiple attempts", zap.Error(err))
 }
 
+// migrateWallets moves the balances that used to be kept on the users table to main wallets,
+// and assigns the transactions recorded before wallets existed to them. It only touches users without a main wallet.
+func migrateWallets() {
+	if !DbConnection.Dialect().HasColumn("users", "balance") {
+		return
+	}
+
+	err := DbConnection.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(
+			`INSERT INTO wallets (user_id, name, balance, created_at, updated_at)
+			SELECT id, ?, COALESCE(balance, 0), NOW(), NOW() FROM users
+			WHERE NOT EXISTS (SELECT 1 FROM wallets WHERE wallets.user_id = users.id AND wallets.name = ?)`,
+			models.MainWallet, models.MainWallet,
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(
+			`UPDATE transactions SET wallet_id = wallets.id FROM wallets
+			WHERE wallets.user_id = transactions.user_id AND wallets.name = ?
+			AND (transactions.wallet_id IS NULL OR transactions.wallet_id = 0)`,
+			models.MainWallet,
+		).Error
+	})
+	if err != nil {
+		logger.Fatal("Failed to migrate balances to wallets", zap.Error(err))
+	}
+}
+
+// classifyDBError tells a missing record from an unavailable database.
+// A missing record is reported as not found with the message; timeouts and connection errors as an unavailable dependency.
+func classifyDBError(err error, notFoundMessage string) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return models.NewError(models.ErrNotFound, notFoundMessage)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return models.WrapError(models.ErrDependencyUnavailable, "Database timed out", err)
+	}
+	return models.WrapError(models.ErrDependencyUnavailable, "Database is unavailable", err)
+}
+
+// isUniqueViolation tells whether a write failed because a row with the same unique key was written to the table meanwhile
+func isUniqueViolation(err error, table string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Table == table
+}
+
 // Global variables to store the Redis client and a sync.Once object
 var redisDefaultClient *redis.Client
 var redisDefaultOnce sync.Once
@@ -63,6 +133,7 @@ func GetRedisDefaultClient() *redis.Client {
 			Addr:     Config.RedisDefaultAddr,
 			Password: Config.RedisPassword,
 		})
+		redisDefaultClient.AddHook(redisTracingHook{})
 	})
 
 	return redisDefaultClient
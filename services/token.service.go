@@ -4,20 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"ledger-service/metrics"
 	db "ledger-service/models"
 	"math/rand"
 	"strconv"
 	"time"
 )
 
-// CreateToken create a new token record
-func CreateToken(email string, tokenType string, expiresAt time.Time) (db.Token, error) {
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again.
+// The whole token family is revoked before this error is returned.
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+// CreateToken create a new token record granting the scopes of the role
+func CreateToken(email string, role string, tokenType string, familyID string, expiresAt time.Time) (db.Token, error) {
 	// Generate a random UUID
 	rand.Seed(time.Now().UnixNano())
 	ID := rand.Int63()
 	claims := &db.UserClaims{
-		Email: email,
-		Type:  tokenType,
+		Email:    email,
+		Type:     tokenType,
+		FamilyID: familyID,
+		Role:     role,
+		Scopes:   db.RoleScopes[role],
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
@@ -25,9 +34,9 @@ func CreateToken(email string, tokenType string, expiresAt time.Time) (db.Token,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(Config.JWTSecretKey))
+	tokenString, err := signToken(claims)
 	if err != nil {
+		metrics.TokenFailures.WithLabelValues(tokenType, metrics.TokenIssue).Inc()
 		return db.Token{}, errors.New("cannot create access token")
 	}
 
@@ -35,14 +44,17 @@ func CreateToken(email string, tokenType string, expiresAt time.Time) (db.Token,
 		ID:          ID,
 		Token:       tokenString,
 		Type:        tokenType,
+		FamilyID:    familyID,
 		ExpiresAt:   expiresAt,
 		Blacklisted: false,
 	}
 
 	if err := DbConnection.Create(&tokenModel).Error; err != nil {
+		metrics.TokenFailures.WithLabelValues(tokenType, metrics.TokenIssue).Inc()
 		return db.Token{}, fmt.Errorf("cannot save access token to db %v", err)
 	}
 
+	metrics.TokensIssued.WithLabelValues(tokenType).Inc()
 	return tokenModel, nil
 }
 
@@ -56,17 +68,24 @@ func DeleteTokenById(tokenId int64) error {
 	return DbConnection.Delete(token).Error
 }
 
-// GenerateAccessTokens generates "access" and "refresh" token for user
+// GenerateAccessTokens generates "access" and "refresh" token for user in a new token family
 func GenerateAccessTokens(email string) (db.Token, db.Token, error) {
+	return generateTokenPair(email, uuid.New().String())
+}
+
+// generateTokenPair generates "access" and "refresh" token belonging to the given family.
+// The role is looked up on every call, so role changes apply when the token is refreshed.
+func generateTokenPair(email string, familyID string) (db.Token, db.Token, error) {
+	role := RoleForEmail(email)
 	accessExpiresAt := time.Now().Add(time.Duration(Config.JWTAccessExpirationMinutes) * time.Minute)
 	refreshExpiresAt := time.Now().Add(time.Duration(Config.JWTRefreshExpirationDays) * time.Hour * 24)
 
-	accessToken, err := CreateToken(email, db.TokenTypeAccess, accessExpiresAt)
+	accessToken, err := CreateToken(email, role, db.TokenTypeAccess, familyID, accessExpiresAt)
 	if err != nil {
 		return db.Token{}, db.Token{}, err
 	}
 
-	refreshToken, err := CreateToken(email, db.TokenTypeRefresh, refreshExpiresAt)
+	refreshToken, err := CreateToken(email, role, db.TokenTypeRefresh, familyID, refreshExpiresAt)
 	if err != nil {
 		return db.Token{}, db.Token{}, err
 	}
@@ -74,26 +93,100 @@ func GenerateAccessTokens(email string) (db.Token, db.Token, error) {
 	return accessToken, refreshToken, nil
 }
 
-// VerifyToken checks jwt validity, expire date, blacklisted
-func VerifyToken(token string, tokenType string) (*db.Token, error) {
-	claims := &db.UserClaims{}
-	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(Config.JWTSecretKey), nil
-	})
+// RotateRefreshToken exchanges a valid refresh token for a new token pair in the same family.
+// The presented token is marked as used; presenting it a second time revokes the whole family.
+// The new tokens are issued for the email in the verified claims.
+func RotateRefreshToken(token string) (db.Token, db.Token, *db.UserClaims, error) {
+	claims, err := parseToken(token, db.TokenTypeRefresh)
+	if err != nil {
+		return db.Token{}, db.Token{}, nil, err
+	}
 
-	if err != nil || claims.Type != tokenType {
-		return nil, errors.New("not valid token")
+	tokenModel := &db.Token{}
+	if err := DbConnection.Where("id = ? AND type = ?", claims.Subject, db.TokenTypeRefresh).First(&tokenModel).Error; err != nil {
+		return db.Token{}, db.Token{}, nil, errors.New("cannot find token")
 	}
 
-	if time.Now().Sub(claims.ExpiresAt.Time) > 10*time.Second {
-		return nil, errors.New("token is expired")
+	// Tokens issued before families existed start a new family on their first rotation
+	familyID := tokenModel.FamilyID
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	// Only one caller can flip the token to blacklisted, so concurrent refreshes with the same token are detected as reuse.
+	// The family is saved on the token in the same update, so reusing a legacy token revokes the pair issued from it.
+	result := DbConnection.Model(&db.Token{}).
+		Where("id = ? AND blacklisted = ?", tokenModel.ID, false).
+		Updates(map[string]interface{}{"blacklisted": true, "family_id": familyID})
+	if result.Error != nil {
+		return db.Token{}, db.Token{}, nil, fmt.Errorf("cannot rotate refresh token %v", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		// A concurrent first rotation of a legacy token may have saved another family, so revoke the saved one
+		if err := DbConnection.Where("id = ?", tokenModel.ID).First(&tokenModel).Error; err == nil && tokenModel.FamilyID != "" {
+			familyID = tokenModel.FamilyID
+		}
+		if err := RevokeTokenFamily(familyID); err != nil {
+			return db.Token{}, db.Token{}, nil, err
+		}
+		return db.Token{}, db.Token{}, nil, ErrRefreshTokenReused
+	}
+
+	accessToken, refreshToken, err := generateTokenPair(claims.Email, familyID)
+	if err != nil {
+		return db.Token{}, db.Token{}, nil, err
+	}
+
+	return accessToken, refreshToken, claims, nil
+}
+
+// RevokeTokenFamily blacklists every access and refresh token of the family
+func RevokeTokenFamily(familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	err := DbConnection.Model(&db.Token{}).
+		Where("family_id = ?", familyID).
+		Update("blacklisted", true).Error
+	if err != nil {
+		return fmt.Errorf("cannot revoke token family %v", err)
+	}
+	return nil
+}
+
+// VerifyToken checks jwt validity, expire date, blacklisted and returns the token with its claims
+func VerifyToken(token string, tokenType string) (*db.Token, *db.UserClaims, error) {
+	claims, err := parseToken(token, tokenType)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	tokenModel := &db.Token{}
 	userId := claims.Subject
 
 	if err := DbConnection.Where("id = ? AND type >= ? AND blacklisted = ?", userId, tokenType, false).First(&tokenModel).Error; err != nil {
-		return &db.Token{}, errors.New("cannot find token")
+		metrics.TokenFailures.WithLabelValues(tokenType, metrics.TokenVerify).Inc()
+		return &db.Token{}, nil, errors.New("cannot find token")
 	}
-	return tokenModel, nil
+	return tokenModel, claims, nil
+}
+
+// parseToken checks the jwt signature, type and expire date and returns its claims
+func parseToken(token string, tokenType string) (*db.UserClaims, error) {
+	claims := &db.UserClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, verificationKey)
+
+	if err != nil || claims.Type != tokenType {
+		metrics.TokenFailures.WithLabelValues(tokenType, metrics.TokenVerify).Inc()
+		return nil, errors.New("not valid token")
+	}
+
+	if time.Now().Sub(claims.ExpiresAt.Time) > 10*time.Second {
+		metrics.TokenFailures.WithLabelValues(tokenType, metrics.TokenVerify).Inc()
+		return nil, errors.New("token is expired")
+	}
+
+	return claims, nil
 }
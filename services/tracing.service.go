@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"os"
+)
+
+// TracerName names the tracer used for every span created by the service
+const TracerName = "ledger-service"
+
+// Supported values of TRACING_EXPORTER
+const (
+	TracingExporterNone = "none"
+	TracingExporterOTLP = "otlp"
+	TracingExporterFile = "file"
+)
+
+// Global variable holding the tracer provider so its spans can be flushed on shutdown
+var tracerProvider *sdktrace.TracerProvider
+
+// Tracer returns the tracer of the service. Spans are dropped until InitTracing installs an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// InitTracing installs the exporter selected by TRACING_EXPORTER and W3C trace context propagation.
+// The OTLP exporter is configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func InitTracing() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch Config.TracingExporter {
+	case TracingExporterNone:
+		return
+	case TracingExporterOTLP:
+		exporter, err = otlptracegrpc.New(context.Background())
+	case TracingExporterFile:
+		var file *os.File
+		file, err = os.OpenFile(Config.TracingFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	}
+	if err != nil {
+		panic(fmt.Errorf("cannot create %s trace exporter %v", Config.TracingExporter, err))
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(Config.TracingSampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(TracerName))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	logger.Info("Tracing enabled", zap.String("exporter", Config.TracingExporter))
+}
+
+// ShutdownTracing flushes the spans that were not exported yet
+func ShutdownTracing(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		logger.Error("Error flushing traces", zap.Error(err))
+	}
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/dialects/postgres"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/models"
+)
+
+// CreateUser opens an active account for the UID in the request with an empty main wallet
+func CreateUser(ctx context.Context, request models.CreateUserRequest) (models.User, error) {
+	db := dbWithContext(ctx)
+
+	user := models.User{
+		UID:     request.UID,
+		Status:  models.UserStatusActive,
+		Wallets: []models.Wallet{{Name: models.MainWallet, Currency: models.DefaultCurrency}},
+	}
+	if len(request.Metadata) > 0 {
+		metadata, err := json.Marshal(request.Metadata)
+		if err != nil {
+			return models.User{}, models.WrapError(models.ErrValidation, "invalid metadata", err)
+		}
+		user.Metadata = postgres.Jsonb{RawMessage: metadata}
+	}
+
+	var count int
+	if err := db.Model(&models.User{}).Where("uid = ?", request.UID).Count(&count).Error; err != nil {
+		return models.User{}, classifyDBError(err, "User not found")
+	}
+	if count > 0 {
+		return models.User{}, models.NewError(models.ErrConflict, "User already exists")
+	}
+
+	// The user and its main wallet are created in one transaction.
+	// A request creating the same UID between the check and the insert trips the unique index instead.
+	if err := db.Create(&user).Error; err != nil {
+		if isUniqueViolation(err, "users") {
+			return models.User{}, models.NewError(models.ErrConflict, "User already exists")
+		}
+		return models.User{}, classifyDBError(err, "User not found")
+	}
+
+	return user, nil
+}
+
+// GetUser returns the account of the UID with its wallets
+func GetUser(ctx context.Context, uid string) (models.User, error) {
+	db := dbWithContext(ctx)
+
+	var user models.User
+	if err := db.Preload("Wallets", orderWallets).Where("uid = ?", uid).First(&user).Error; err != nil {
+		return models.User{}, classifyDBError(err, "User not found")
+	}
+	return user, nil
+}
+
+// CreateWallet adds an empty wallet with the name in the request to the account of the UID.
+// The account lock keeps it from being added while the account is closed or frozen.
+func CreateWallet(ctx context.Context, uid string, request models.CreateWalletRequest) (models.Wallet, error) {
+	db := dbWithContext(ctx)
+
+	mutex, err := lockUser(ctx, ctx, uid)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+	defer releaseLock(mutex)
+
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+	if user.Status == models.UserStatusClosed {
+		return models.Wallet{}, models.NewError(models.ErrAccountClosed, "Account is closed")
+	}
+	for _, wallet := range user.Wallets {
+		if wallet.Name == request.Name {
+			return models.Wallet{}, models.NewError(models.ErrConflict, "Wallet already exists")
+		}
+	}
+
+	wallet := models.Wallet{UserID: user.ID, Name: request.Name, Currency: request.Currency}
+	if wallet.Currency == "" {
+		wallet.Currency = models.DefaultCurrency
+	}
+	if err := db.Create(&wallet).Error; err != nil {
+		return models.Wallet{}, classifyDBError(err, "Wallet not found")
+	}
+	return wallet, nil
+}
+
+// ListWallets returns the wallets of the account of the UID with their balances
+func ListWallets(ctx context.Context, uid string) ([]models.Wallet, error) {
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return user.Wallets, nil
+}
+
+// orderWallets lists the main wallet first and the others by name
+func orderWallets(db *gorm.DB) *gorm.DB {
+	return db.Order(gorm.Expr("name <> ?, name", models.MainWallet))
+}
+
+// FreezeUser freezes the account of the UID so it cannot be debited, nor credited when credits are blocked.
+// Freezing a frozen account again only changes whether credits are blocked.
+func FreezeUser(ctx context.Context, uid string, request models.FreezeUserRequest, actor string) (models.User, error) {
+	return changeUserStatus(ctx, uid, func(user models.User) error {
+		switch {
+		case user.Status == models.UserStatusClosed:
+			return models.NewError(models.ErrAccountClosed, "Account is closed")
+		case user.Status == models.UserStatusFrozen && user.CreditsBlocked == request.BlockCredits:
+			return models.NewError(models.ErrConflict, "Account is already frozen")
+		}
+		return nil
+	}, nil, models.AccountStatusChange{
+		ToStatus:       models.UserStatusFrozen,
+		CreditsBlocked: request.BlockCredits,
+		ReasonCode:     request.ReasonCode,
+		Note:           request.Note,
+		Actor:          actor,
+	})
+}
+
+// UnfreezeUser makes a frozen account of the UID active again
+func UnfreezeUser(ctx context.Context, uid string, request models.UnfreezeUserRequest, actor string) (models.User, error) {
+	return changeUserStatus(ctx, uid, func(user models.User) error {
+		if user.Status != models.UserStatusFrozen {
+			return models.NewError(models.ErrConflict, "Account is not frozen")
+		}
+		return nil
+	}, nil, models.AccountStatusChange{
+		ToStatus:   models.UserStatusActive,
+		ReasonCode: request.ReasonCode,
+		Note:       request.Note,
+		Actor:      actor,
+	})
+}
+
+// CloseUser closes the account of the UID. Its wallets have to be empty, unless the request sweeps their balances
+// to the settlement account with recorded transactions, each to its wallet in the same currency. Closed accounts
+// reject credits but keep their history.
+func CloseUser(ctx context.Context, uid string, request models.CloseUserRequest, actor string) (models.User, error) {
+	var settlement models.User
+	settlementWallets := map[string]*models.Wallet{}
+	var settlementMutexes []*redsync.Mutex
+	defer func() {
+		releaseLocks(settlementMutexes)
+	}()
+
+	check := func(user models.User) error {
+		switch {
+		case user.Status == models.UserStatusClosed:
+			return models.NewError(models.ErrAccountClosed, "Account is already closed")
+		case isOverdrawn(user):
+			return models.NewError(models.ErrConflict, "Account is overdrawn, its overdraft has to be repaid before closing it")
+		case totalBalance(user) == 0:
+			return nil
+		case !request.SweepBalance:
+			return models.NewError(models.ErrConflict, "Account balance must be zero to close it")
+		case user.Status == models.UserStatusFrozen:
+			return models.NewError(models.ErrAccountFrozen, "Account is frozen, its balance cannot be swept")
+		case Config.SettlementAccountUID == "":
+			return models.NewError(models.ErrValidation, "No settlement account is configured")
+		case Config.SettlementAccountUID == uid:
+			return models.NewError(models.ErrConflict, "The settlement account cannot be swept into itself")
+		}
+
+		db := dbWithContext(ctx)
+		if err := db.Where("uid = ?", Config.SettlementAccountUID).First(&settlement).Error; err != nil {
+			return classifyDBError(err, "Settlement account not found")
+		}
+		var names []string
+		var wallets []*models.Wallet
+		for _, wallet := range user.Wallets {
+			if wallet.Balance == 0 || settlementWallets[wallet.Currency] != nil {
+				continue
+			}
+			settlementWallet, err := findCurrencyWallet(db, settlement, wallet.Currency)
+			if err != nil {
+				return err
+			}
+			settlementWallets[wallet.Currency] = &settlementWallet
+			names = append(names, settlementWallet.Name)
+			wallets = append(wallets, &settlementWallet)
+		}
+
+		// Lock the settlement wallets too, so their balances are not changed by a concurrent credit
+		var err error
+		settlementMutexes, err = lockWallets(ctx, ctx, settlement.UID, names)
+		if err != nil {
+			return err
+		}
+		if err := reloadLocked(db, &settlement, wallets...); err != nil {
+			return classifyDBError(err, "Settlement account not found")
+		}
+		if settlement.Status == models.UserStatusClosed {
+			return models.NewError(models.ErrAccountClosed, "Settlement account is closed")
+		}
+		if settlement.Status == models.UserStatusFrozen && settlement.CreditsBlocked {
+			return models.NewError(models.ErrAccountFrozen, "Settlement account is frozen")
+		}
+		return nil
+	}
+
+	apply := func(tx *gorm.DB, user *models.User) error {
+		for i := range user.Wallets {
+			wallet := &user.Wallets[i]
+			if wallet.Balance == 0 {
+				continue
+			}
+			err := postTransfer(tx, wallet, settlementWallets[wallet.Currency], wallet.Balance, uuid.New().String(), models.TransactionTypeDebit, models.TransactionTypeCredit)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	user, err := changeUserStatus(ctx, uid, check, apply, models.AccountStatusChange{
+		ToStatus:   models.UserStatusClosed,
+		ReasonCode: request.ReasonCode,
+		Note:       request.Note,
+		Actor:      actor,
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	for _, wallet := range user.Wallets {
+		invalidateBalanceCache(ctx, ctx, uid, wallet.Name)
+	}
+	for _, wallet := range settlementWallets {
+		invalidateBalanceCache(ctx, ctx, settlement.UID, wallet.Name)
+	}
+	return user, nil
+}
+
+// isOverdrawn reports whether a wallet of the user has a negative balance
+func isOverdrawn(user models.User) bool {
+	for _, wallet := range user.Wallets {
+		if wallet.Balance < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// totalBalance returns the sum of the balances of the wallets of the user
+func totalBalance(user models.User) float64 {
+	var total float64
+	for _, wallet := range user.Wallets {
+		total += wallet.Balance
+	}
+	return total
+}
+
+// ListUserStatusChanges returns the audit trail of state changes of the account of the UID, newest first
+func ListUserStatusChanges(ctx context.Context, uid string) ([]models.AccountStatusChange, error) {
+	db := dbWithContext(ctx)
+
+	if _, err := GetUser(ctx, uid); err != nil {
+		return nil, err
+	}
+
+	var changes []models.AccountStatusChange
+	if err := db.Where("uid = ?", uid).Order("created_at desc").Find(&changes).Error; err != nil {
+		return nil, classifyDBError(err, "Status changes not found")
+	}
+	return changes, nil
+}
+
+// changeUserStatus applies a state change to the account of the UID while holding the account lock and the locks
+// of all its wallets, so no credit or debit can run against the old state and no wallet can be added meanwhile,
+// and records it in the audit table.
+// check is called with the locked user and its wallets and rejects changes that are not allowed from its state.
+// apply, when set, runs in the same database transaction before the state is changed.
+func changeUserStatus(
+	ctx context.Context,
+	uid string,
+	check func(user models.User) error,
+	apply func(tx *gorm.DB, user *models.User) error,
+	change models.AccountStatusChange,
+) (models.User, error) {
+	done, err := beginLedgerWrite()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer done()
+
+	db := dbWithContext(ctx)
+	logCtx := logger.WithFields(ctx, zap.String("to_status", change.ToStatus), zap.String("reason_code", change.ReasonCode))
+
+	userMutex, err := lockUser(ctx, logCtx, uid)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer releaseLock(userMutex)
+
+	user, err := GetUser(ctx, uid)
+	if err != nil {
+		return models.User{}, err
+	}
+	walletNames := make([]string, 0, len(user.Wallets))
+	for _, wallet := range user.Wallets {
+		walletNames = append(walletNames, wallet.Name)
+	}
+
+	mutexes, err := lockWallets(ctx, logCtx, uid, walletNames)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer releaseLocks(mutexes)
+
+	// Reload the user and its wallets so the state and balances cannot change underneath the locks
+	if user, err = GetUser(ctx, uid); err != nil {
+		return models.User{}, err
+	}
+	if err := check(user); err != nil {
+		return models.User{}, err
+	}
+
+	change.UserID = user.ID
+	change.UID = user.UID
+	change.FromStatus = user.Status
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return models.User{}, classifyDBError(tx.Error, "User not found")
+	}
+	if apply != nil {
+		err = apply(tx, &user)
+	}
+	if err == nil {
+		// The wallets were loaded with the user, but only the state is updated here
+		err = tx.Set("gorm:save_associations", false).Model(&user).Updates(map[string]interface{}{
+			"status":          change.ToStatus,
+			"credits_blocked": change.CreditsBlocked,
+		}).Error
+	}
+	if err == nil {
+		err = tx.Create(&change).Error
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		var domainErr *models.Error
+		if errors.As(err, &domainErr) {
+			return models.User{}, err
+		}
+		logger.ErrorCtx(logCtx, "Error changing account status", zap.Error(err))
+		return models.User{}, classifyDBError(err, "User not found")
+	}
+
+	logger.InfoCtx(logCtx, "Account status changed", zap.String("from_status", change.FromStatus), zap.String("actor", change.Actor))
+	return user, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"ledger-service/logger"
+	"ledger-service/metrics"
+	"ledger-service/models"
+	"time"
+)
+
+// Names of the velocity limits, as reported in errors and metrics
+const (
+	VelocityTransactionAmount   = "transaction_amount"
+	VelocityDailyCredit         = "daily_credit"
+	VelocityWeeklyCredit        = "weekly_credit"
+	VelocityDailyDebit          = "daily_debit"
+	VelocityTransactionsPerHour = "transactions_per_hour"
+)
+
+// velocityCounter is a running total of a UID within a fixed window that is capped by a velocity limit
+type velocityCounter struct {
+	name      string
+	limit     float64
+	increment float64
+	start     time.Time
+	end       time.Time
+	// types are the transaction types counted, and count tells whether transactions are counted instead of summed
+	types []string
+	count bool
+}
+
+// key is the Redis key of the counter of the user in the current window
+func (c velocityCounter) key(user models.User) string {
+	return fmt.Sprintf("velocity:%s:%s:%d", user.UID, c.name, c.start.Unix())
+}
+
+// checkTransactionAmount rejects amounts above the maximum of a single transaction
+func checkTransactionAmount(amount float64) error {
+	if Config.LimitMaxTransactionAmount > 0 && amount > Config.LimitMaxTransactionAmount {
+		metrics.LimitRejections.WithLabelValues(VelocityTransactionAmount).Inc()
+		return models.NewError(models.ErrLimitExceeded, fmt.Sprintf("Amount exceeds the limit of %.2f per transaction", Config.LimitMaxTransactionAmount))
+	}
+	return nil
+}
+
+// reserveVelocity checks a credit or debit of the user against the velocity limits and reserves it in the counters.
+// Counters live in Redis and are rebuilt from Postgres when missing. When a counter command fails, the limit is
+// checked against the committed transactions in Postgres instead, without a reservation: writes of the UID that run
+// concurrently in other wallets can then together exceed the limit. This only covers failures of single counter keys;
+// every caller holds a wallet lock in the same Redis, so with Redis down the write already failed on the lock.
+// The returned release gives the reservation back and has to be called when the write does not happen.
+func reserveVelocity(ctx context.Context, logCtx context.Context, user models.User, transactionType string, amount float64) (func(), error) {
+	if err := checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+
+	redisClient := GetRedisDefaultClient()
+	var reserved []velocityCounter
+	release := func() {
+		for _, counter := range reserved {
+			if err := redisClient.IncrByFloat(ctx, counter.key(user), -counter.increment).Err(); err != nil {
+				logger.ErrorCtx(logCtx, "Error releasing velocity counter", zap.String("limit", counter.name), zap.Error(err))
+			}
+		}
+	}
+
+	for _, counter := range velocityCounters(transactionType, amount, time.Now()) {
+		total, err := reserveCounter(ctx, user, counter)
+		if err != nil {
+			// The counter failed, check the limit against Postgres without reserving
+			logger.ErrorCtx(logCtx, "Error updating velocity counter, falling back to the database", zap.String("limit", counter.name), zap.Error(err))
+			current, dbErr := loadCounter(ctx, user, counter)
+			if dbErr != nil {
+				release()
+				return nil, classifyDBError(dbErr, "User not found")
+			}
+			total = current + counter.increment
+		} else {
+			reserved = append(reserved, counter)
+		}
+
+		if total > counter.limit {
+			release()
+			metrics.LimitRejections.WithLabelValues(counter.name).Inc()
+			return nil, models.NewError(models.ErrLimitExceeded, fmt.Sprintf("The %s limit of %g is exceeded", counter.name, counter.limit))
+		}
+	}
+	return release, nil
+}
+
+// velocityCounters returns the counters with a configured limit that a transaction of the type updates
+func velocityCounters(transactionType string, amount float64, now time.Time) []velocityCounter {
+	now = now.UTC()
+	hour := now.Truncate(time.Hour)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	week := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+
+	counted := []string{models.TransactionTypeCredit, models.TransactionTypeDebit}
+	counters := []velocityCounter{
+		{name: VelocityTransactionsPerHour, limit: float64(Config.LimitMaxTransactionsPerHour), increment: 1, start: hour, end: hour.Add(time.Hour), types: counted, count: true},
+	}
+	switch transactionType {
+	case models.TransactionTypeCredit:
+		counters = append(counters,
+			velocityCounter{name: VelocityDailyCredit, limit: Config.LimitMaxDailyCredit, increment: amount, start: day, end: day.AddDate(0, 0, 1), types: []string{transactionType}},
+			velocityCounter{name: VelocityWeeklyCredit, limit: Config.LimitMaxWeeklyCredit, increment: amount, start: week, end: week.AddDate(0, 0, 7), types: []string{transactionType}},
+		)
+	case models.TransactionTypeDebit:
+		counters = append(counters,
+			velocityCounter{name: VelocityDailyDebit, limit: Config.LimitMaxDailyDebit, increment: amount, start: day, end: day.AddDate(0, 0, 1), types: []string{transactionType}},
+		)
+	}
+
+	configured := counters[:0]
+	for _, counter := range counters {
+		if counter.limit > 0 {
+			configured = append(configured, counter)
+		}
+	}
+	return configured
+}
+
+// reserveCounter adds the increment to the Redis counter and returns the new total.
+// A missing counter, after an eviction or a Redis restart, is first rebuilt from Postgres.
+func reserveCounter(ctx context.Context, user models.User, counter velocityCounter) (float64, error) {
+	redisClient := GetRedisDefaultClient()
+	key := counter.key(user)
+
+	exists, err := redisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		current, err := loadCounter(ctx, user, counter)
+		if err != nil {
+			return 0, err
+		}
+		// Keep the counter a minute past the end of its window
+		if err := redisClient.SetNX(ctx, key, current, time.Until(counter.end)+time.Minute).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return redisClient.IncrByFloat(ctx, key, counter.increment).Result()
+}
+
+// loadCounter reads the total of the counter in its window from the transactions in Postgres
+func loadCounter(ctx context.Context, user models.User, counter velocityCounter) (float64, error) {
+	aggregate := "COALESCE(SUM(amount), 0)"
+	if counter.count {
+		aggregate = "COUNT(*)"
+	}
+
+	var total struct{ Total float64 }
+	err := dbWithContext(ctx).Model(&models.Transaction{}).
+		Select(aggregate+" AS total").
+		Where("user_id = ? AND type IN (?) AND created_at >= ?", user.ID, counter.types, counter.start).
+		Scan(&total).Error
+	return total.Total, err
+}
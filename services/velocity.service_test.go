@@ -0,0 +1,99 @@
+package services
+
+import (
+	"github.com/stretchr/testify/require"
+	"ledger-service/models"
+	"testing"
+	"time"
+)
+
+// withConfig sets the configuration for a test and restores the loaded one after it
+func withConfig(t *testing.T, config models.EnvConfig) {
+	loaded := Config
+	Config = &config
+	t.Cleanup(func() { Config = loaded })
+}
+
+func TestVelocityCounters(t *testing.T) {
+	withConfig(t, models.EnvConfig{
+		LimitMaxDailyCredit:         1000,
+		LimitMaxWeeklyCredit:        5000,
+		LimitMaxDailyDebit:          500,
+		LimitMaxTransactionsPerHour: 20,
+	})
+	berlin := time.FixedZone("CEST", 2*60*60)
+	date := func(year int, month time.Month, day int, hour int, minute int, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	}
+
+	type window struct {
+		name  string
+		start time.Time
+		end   time.Time
+	}
+	tests := []struct {
+		name            string
+		transactionType string
+		now             time.Time
+		want            []window
+	}{
+		{
+			name:            "credit on the last second of a Sunday",
+			transactionType: models.TransactionTypeCredit,
+			now:             date(2026, 10, 18, 23, 59, 59),
+			want: []window{
+				{VelocityTransactionsPerHour, date(2026, 10, 18, 23, 0, 0), date(2026, 10, 19, 0, 0, 0)},
+				{VelocityDailyCredit, date(2026, 10, 18, 0, 0, 0), date(2026, 10, 19, 0, 0, 0)},
+				{VelocityWeeklyCredit, date(2026, 10, 12, 0, 0, 0), date(2026, 10, 19, 0, 0, 0)},
+			},
+		},
+		{
+			name:            "credit at the start of a Monday",
+			transactionType: models.TransactionTypeCredit,
+			now:             date(2026, 10, 19, 0, 0, 0),
+			want: []window{
+				{VelocityTransactionsPerHour, date(2026, 10, 19, 0, 0, 0), date(2026, 10, 19, 1, 0, 0)},
+				{VelocityDailyCredit, date(2026, 10, 19, 0, 0, 0), date(2026, 10, 20, 0, 0, 0)},
+				{VelocityWeeklyCredit, date(2026, 10, 19, 0, 0, 0), date(2026, 10, 26, 0, 0, 0)},
+			},
+		},
+		{
+			name:            "credit in another time zone uses the UTC day",
+			transactionType: models.TransactionTypeCredit,
+			now:             time.Date(2026, 10, 20, 1, 30, 0, 0, berlin),
+			want: []window{
+				{VelocityTransactionsPerHour, date(2026, 10, 19, 23, 0, 0), date(2026, 10, 20, 0, 0, 0)},
+				{VelocityDailyCredit, date(2026, 10, 19, 0, 0, 0), date(2026, 10, 20, 0, 0, 0)},
+				{VelocityWeeklyCredit, date(2026, 10, 19, 0, 0, 0), date(2026, 10, 26, 0, 0, 0)},
+			},
+		},
+		{
+			name:            "debit over a month end",
+			transactionType: models.TransactionTypeDebit,
+			now:             date(2026, 10, 31, 12, 15, 0),
+			want: []window{
+				{VelocityTransactionsPerHour, date(2026, 10, 31, 12, 0, 0), date(2026, 10, 31, 13, 0, 0)},
+				{VelocityDailyDebit, date(2026, 10, 31, 0, 0, 0), date(2026, 11, 1, 0, 0, 0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []window
+			for _, counter := range velocityCounters(tt.transactionType, 100, tt.now) {
+				got = append(got, window{counter.name, counter.start, counter.end})
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVelocityCountersSkipUnsetLimits(t *testing.T) {
+	withConfig(t, models.EnvConfig{LimitMaxWeeklyCredit: 5000})
+
+	counters := velocityCounters(models.TransactionTypeCredit, 250, time.Date(2026, 10, 21, 8, 0, 0, 0, time.UTC))
+	require.Len(t, counters, 1)
+	require.Equal(t, VelocityWeeklyCredit, counters[0].name)
+	require.Equal(t, 250.0, counters[0].increment)
+	require.False(t, counters[0].count)
+}